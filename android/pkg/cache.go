@@ -0,0 +1,184 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long the package list is cached by LookupUid.
+const DefaultTTL = 30 * time.Second
+
+// perUserRange is the number of uids reserved for each Android user; a
+// uid is userId * perUserRange + appId.
+const perUserRange = 100000
+
+// ErrNotFound is returned when no package owns a uid.
+var ErrNotFound = errors.New("pkg: no package for uid")
+
+// Cache maps uids to packages. The package list is read at most once per
+// TTL, so lookups on hot paths (e.g. once per connection) are cheap. The
+// list is read without blocking lookups; if reading it fails, the old
+// list keeps being served and the read is retried with a backoff. It is
+// safe for concurrent use.
+type Cache struct {
+	mu     sync.RWMutex
+	loadMu sync.Mutex // serializes loads; never held with mu
+
+	ttl   time.Duration
+	load  func() ([]Pkg, error)
+	byUid map[uint32][]Pkg
+	stamp time.Time // of the last successful load
+
+	// after a failed load: the error, and when to try again
+	err     error
+	retry   time.Time
+	backoff time.Duration
+}
+
+// NewCache returns a Cache that re-reads the installed packages when its
+// contents are older than ttl.
+func NewCache(ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	c := &Cache{
+		ttl:  ttl,
+		load: Installed,
+	}
+	return c
+}
+
+var defaultCache = NewCache(DefaultTTL)
+
+// LookupUid returns the package that owns uid using a process wide cache.
+// See Cache.LookupUid.
+func LookupUid(uid uint32) (*Pkg, error) {
+	return defaultCache.LookupUid(uid)
+}
+
+// LookupUid returns the package that owns uid. Uids of secondary Android
+// users are mapped to the corresponding app id. If several packages share
+// the uid, the first one listed is returned; see LookupUidAll.
+func (c *Cache) LookupUid(uid uint32) (*Pkg, error) {
+	v, err := c.LookupUidAll(uid)
+	if err != nil {
+		return nil, err
+	}
+	p := v[0]
+	return &p, nil
+}
+
+// LookupUidAll returns all the packages that share uid.
+func (c *Cache) LookupUidAll(uid uint32) ([]Pkg, error) {
+	if err := c.refresh(false); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	v, ok := c.byUid[uid]
+	if !ok {
+		v, ok = c.byUid[uid%perUserRange]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrNotFound, uid)
+	}
+	return append([]Pkg(nil), v...), nil
+}
+
+// Refresh re-reads the package list regardless of its age, e.g. after a
+// package was installed.
+func (c *Cache) Refresh() error {
+	return c.refresh(true)
+}
+
+// refresh reloads the package list if it is older than the TTL and no
+// failed load is backing off. Lookups don't wait for another goroutine's
+// load, or report a failed one, while there is an old list to serve.
+func (c *Cache) refresh(force bool) error {
+	if !force {
+		load, have, err := c.state()
+		if !load {
+			return err
+		}
+		if !have {
+			c.loadMu.Lock()
+		} else if !c.loadMu.TryLock() {
+			return nil
+		}
+	} else {
+		c.loadMu.Lock()
+	}
+	defer c.loadMu.Unlock()
+
+	if !force {
+		// someone else may have loaded while we waited for loadMu
+		if load, _, err := c.state(); !load {
+			return err
+		}
+	}
+
+	// pm may take a while; lookups keep using the old map meanwhile
+	pkgs, err := c.load()
+	if err != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.backoff = min(max(2*c.backoff, time.Second), c.ttl)
+		c.retry = time.Now().Add(c.backoff)
+		c.err = err
+		if c.byUid != nil && !force {
+			return nil
+		}
+		return err
+	}
+
+	m := make(map[uint32][]Pkg, len(pkgs))
+	for _, p := range pkgs {
+		m[p.Uid] = append(m[p.Uid], p)
+	}
+
+	c.mu.Lock()
+	c.byUid = m
+	c.stamp = time.Now()
+	c.err, c.retry, c.backoff = nil, time.Time{}, 0
+	c.mu.Unlock()
+	return nil
+}
+
+// state reports whether the list should be loaded now and whether there
+// is a list to serve meanwhile. While a failed load backs off with no
+// list to serve, err is its error.
+func (c *Cache) state() (load, have bool, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	have = c.byUid != nil
+	switch {
+	case have && time.Since(c.stamp) < c.ttl:
+		return false, true, nil
+	case time.Now().Before(c.retry):
+		if have {
+			return false, true, nil
+		}
+		return false, false, c.err
+	}
+	return true, have, nil
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var loads int
+	pkgs := []Pkg{
+		{Name: "com.example.app", Uid: 10123},
+		{Name: "com.shared.a", Uid: 1000},
+		{Name: "com.shared.b", Uid: 1000},
+	}
+
+	c := NewCache(50 * time.Millisecond)
+	c.load = func() ([]Pkg, error) {
+		loads++
+		return pkgs, nil
+	}
+
+	p, err := c.LookupUid(10123)
+	if err != nil || p.Name != "com.example.app" {
+		t.Fatalf("lookup 10123: got %v, %v", p, err)
+	}
+
+	// secondary user
+	p, err = c.LookupUid(10*perUserRange + 10123)
+	if err != nil || p.Name != "com.example.app" {
+		t.Fatalf("lookup user 10: got %v, %v", p, err)
+	}
+
+	v, err := c.LookupUidAll(1000)
+	if err != nil || len(v) != 2 {
+		t.Fatalf("lookup shared uid: got %v, %v", v, err)
+	}
+
+	if _, err := c.LookupUid(4242); !errors.Is(err, ErrNotFound) {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+	if loads != 1 {
+		t.Errorf("want 1 load within TTL, got %d", loads)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	c.LookupUid(10123)
+	if loads != 2 {
+		t.Errorf("want reload after TTL, got %d loads", loads)
+	}
+
+	c.Refresh()
+	if loads != 3 {
+		t.Errorf("want reload on Refresh, got %d loads", loads)
+	}
+}
+
+func TestCacheLoadError(t *testing.T) {
+	var loads int
+	fail := errors.New("pm failed")
+	var err error
+
+	c := NewCache(50 * time.Millisecond)
+	c.load = func() ([]Pkg, error) {
+		loads++
+		if err != nil {
+			return nil, err
+		}
+		return []Pkg{{Name: "com.example.app", Uid: 10123}}, nil
+	}
+
+	// nothing to serve yet: the error is reported, and not retried at once
+	err = fail
+	if _, e := c.LookupUid(10123); e != fail {
+		t.Fatalf("want the load error, got %v", e)
+	}
+	if _, e := c.LookupUid(10123); e != fail || loads != 1 {
+		t.Fatalf("want the load error without a reload, got %v after %d loads", e, loads)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	err = nil
+	if p, e := c.LookupUid(10123); e != nil || p.Name != "com.example.app" {
+		t.Fatalf("lookup after backoff: got %v, %v", p, e)
+	}
+
+	// a failed reload keeps serving the old list
+	time.Sleep(60 * time.Millisecond)
+	err = fail
+	loads = 0
+	for i := 0; i < 3; i++ {
+		if p, e := c.LookupUid(10123); e != nil || p.Name != "com.example.app" {
+			t.Fatalf("stale lookup: got %v, %v", p, e)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("want 1 load while backing off, got %d", loads)
+	}
+	if e := c.Refresh(); e != fail {
+		t.Errorf("Refresh: want the load error, got %v", e)
+	}
+}
+
+func TestCacheSlowLoad(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var slow atomic.Bool
+
+	c := NewCache(20 * time.Millisecond)
+	c.load = func() ([]Pkg, error) {
+		if slow.Load() {
+			close(started)
+			<-release
+		}
+		return []Pkg{{Name: "com.example.app", Uid: 10123}}, nil
+	}
+	c.LookupUid(10123)
+
+	time.Sleep(30 * time.Millisecond)
+	slow.Store(true)
+	go c.Refresh()
+	defer close(release)
+	<-started
+
+	// lookups keep using the old list while the reload is stuck
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.LookupUid(10123)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("lookup: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("lookup blocked on a slow load")
+	}
+}
@@ -0,0 +1,156 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Permission is a permission requested by a package.
+type Permission struct {
+	Name    string
+	Granted bool
+
+	// Runtime is true for "dangerous" permissions that the user
+	// grants at runtime, as opposed to install time permissions.
+	Runtime bool
+}
+
+// Permissions returns the permissions requested by the package name,
+// sorted by permission name, as reported by "pm dump". Runtime permissions
+// reflect the primary user.
+func Permissions(name string) ([]Permission, error) {
+	out, err := exec.Command("pm", "dump", name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("pkg: pm dump %s: %w", name, err)
+	}
+
+	perms, err := parsePermissions(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	if len(perms) == 0 && !bytes.Contains(out, []byte("Package ["+name+"]")) {
+		return nil, fmt.Errorf("pkg: %s: not installed", name)
+	}
+	return perms, nil
+}
+
+// HasPermission returns true if the package name was granted perm.
+func HasPermission(name, perm string) (bool, error) {
+	perms, err := Permissions(name)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range perms {
+		if p.Name == perm {
+			return p.Granted, nil
+		}
+	}
+	return false, nil
+}
+
+// parsePermissions extracts the "requested", "install" and "runtime"
+// permission sections of a package dump. The sections look like:
+//
+//	requested permissions:
+//	  android.permission.INTERNET
+//	install permissions:
+//	  android.permission.INTERNET: granted=true
+//	User 0: ...
+//	  runtime permissions:
+//	    android.permission.CAMERA: granted=false, flags=[ USER_SET ]
+//
+// Only the first set of runtime permissions (the primary user) is used.
+func parsePermissions(r io.Reader) ([]Permission, error) {
+	const (
+		none = iota
+		requested
+		install
+		runtime
+	)
+
+	perms := make(map[string]*Permission)
+	get := func(nm string) *Permission {
+		p, ok := perms[nm]
+		if !ok {
+			p = &Permission{Name: nm}
+			perms[nm] = p
+		}
+		return p
+	}
+
+	var sect, indent int
+	var seenRuntime bool
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		s := strings.TrimSpace(line)
+		if len(s) == 0 {
+			continue
+		}
+		ind := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		switch s {
+		case "requested permissions:":
+			sect, indent = requested, ind
+			continue
+		case "install permissions:":
+			sect, indent = install, ind
+			continue
+		case "runtime permissions:":
+			sect, indent = none, ind
+			if !seenRuntime {
+				sect, seenRuntime = runtime, true
+			}
+			continue
+		}
+
+		if ind <= indent {
+			sect = none
+		}
+		if sect == none {
+			continue
+		}
+
+		nm, rest, _ := strings.Cut(s, ":")
+		p := get(nm)
+		switch sect {
+		case install:
+			p.Granted = strings.Contains(rest, "granted=true")
+		case runtime:
+			p.Runtime = true
+			p.Granted = strings.Contains(rest, "granted=true")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	v := make([]Permission, 0, len(perms))
+	for _, p := range perms {
+		v = append(v, *p)
+	}
+	sort.Slice(v, func(i, j int) bool {
+		return v[i].Name < v[j].Name
+	})
+	return v, nil
+}
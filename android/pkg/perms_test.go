@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDump = `Packages:
+  Package [com.example.app] (1a2b3c):
+    userId=10123
+    declared permissions:
+      com.example.app.PRIVATE: prot=signature
+    requested permissions:
+      android.permission.INTERNET
+      android.permission.CAMERA
+      android.permission.READ_CONTACTS
+    install permissions:
+      android.permission.INTERNET: granted=true
+    User 0: ceDataInode=1234 installed=true hidden=false
+      gids=[3003]
+      runtime permissions:
+        android.permission.CAMERA: granted=true, flags=[ USER_SET ]
+        android.permission.READ_CONTACTS: granted=false, flags=[ USER_FIXED ]
+    User 10: ceDataInode=5678 installed=true hidden=false
+      runtime permissions:
+        android.permission.CAMERA: granted=false, flags=[ ]
+`
+
+func TestParsePermissions(t *testing.T) {
+	got, err := parsePermissions(strings.NewReader(testDump))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	want := []Permission{
+		{"android.permission.CAMERA", true, true},
+		{"android.permission.INTERNET", true, false},
+		{"android.permission.READ_CONTACTS", false, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d permissions, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d: want %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package pkg provides information about the packages (apps) installed on
+// an Android device.
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// PackagesList is the file in which the package manager records every
+// installed package; reading it requires system privileges.
+const PackagesList = "/data/system/packages.list"
+
+// Pkg describes an installed package.
+type Pkg struct {
+	Name        string
+	Uid         uint32
+	VersionCode int64
+	DataDir     string
+}
+
+// Installed returns the list of installed packages. It parses
+// PackagesList and falls back to querying "pm" when the file isn't
+// readable (e.g. for unprivileged callers); in the latter case DataDir is
+// not known.
+func Installed() ([]Pkg, error) {
+	fd, err := os.Open(PackagesList)
+	if err == nil {
+		defer fd.Close()
+		return parsePackagesList(fd)
+	}
+
+	out, xerr := exec.Command("pm", "list", "packages", "-U", "--show-versioncode").Output()
+	if xerr != nil {
+		return nil, fmt.Errorf("pkg: %w; pm: %s", err, xerr)
+	}
+	return parsePmList(bytes.NewReader(out))
+}
+
+// parsePackagesList parses the contents of packages.list. Each line is:
+//
+//	name uid debuggable datadir seinfo gids [profileable [versioncode]]
+//
+// The trailing fields only exist on newer versions of Android.
+func parsePackagesList(r io.Reader) ([]Pkg, error) {
+	var pkgs []Pkg
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		v := strings.Fields(sc.Text())
+		if len(v) == 0 {
+			continue
+		}
+		if len(v) < 4 {
+			return nil, fmt.Errorf("%s:%d: too few fields", PackagesList, n)
+		}
+
+		uid, err := strconv.ParseUint(v[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid uid %q", PackagesList, n, v[1])
+		}
+
+		p := Pkg{
+			Name:    v[0],
+			Uid:     uint32(uid),
+			DataDir: v[3],
+		}
+		if len(v) >= 8 {
+			if p.VersionCode, err = strconv.ParseInt(v[7], 10, 64); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid version code %q", PackagesList, n, v[7])
+			}
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, sc.Err()
+}
+
+// parsePmList parses the output of "pm list packages -U --show-versioncode":
+//
+//	package:com.example versionCode:42 uid:10123
+func parsePmList(r io.Reader) ([]Pkg, error) {
+	var pkgs []Pkg
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		var p Pkg
+		for _, f := range strings.Fields(sc.Text()) {
+			k, v, ok := strings.Cut(f, ":")
+			if !ok {
+				continue
+			}
+
+			switch k {
+			case "package":
+				p.Name = v
+			case "versionCode":
+				p.VersionCode, _ = strconv.ParseInt(v, 10, 64)
+			case "uid":
+				// shared uids are listed as "uid:10001,10002"
+				v, _, _ = strings.Cut(v, ",")
+				uid, err := strconv.ParseUint(v, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("pm: invalid uid %q", v)
+				}
+				p.Uid = uint32(uid)
+			}
+		}
+
+		if len(p.Name) > 0 {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs, sc.Err()
+}
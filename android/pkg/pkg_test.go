@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePackagesList(t *testing.T) {
+	in := `com.android.shell 2000 0 /data/user_de/0/com.android.shell platform:privapp:targetSdkVersion=29 3002,3003 0 29
+com.example.app 10123 1 /data/user/0/com.example.app default:targetSdkVersion=30 none 0 4201
+
+com.legacy 10050 0 /data/data/com.legacy default 3003
+`
+	want := []Pkg{
+		{"com.android.shell", 2000, 29, "/data/user_de/0/com.android.shell"},
+		{"com.example.app", 10123, 4201, "/data/user/0/com.example.app"},
+		{"com.legacy", 10050, 0, "/data/data/com.legacy"},
+	}
+
+	got, err := parsePackagesList(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d packages, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d: want %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	if _, err := parsePackagesList(strings.NewReader("com.bad notanumber 0 /data\n")); err == nil {
+		t.Errorf("expected error for invalid uid")
+	}
+}
+
+func TestParsePmList(t *testing.T) {
+	in := "package:com.example.app versionCode:4201 uid:10123\npackage:com.shared versionCode:1 uid:1000,1001\n"
+	want := []Pkg{
+		{Name: "com.example.app", Uid: 10123, VersionCode: 4201},
+		{Name: "com.shared", Uid: 1000, VersionCode: 1},
+	}
+
+	got, err := parsePmList(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d packages, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%d: want %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
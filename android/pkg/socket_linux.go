@@ -0,0 +1,175 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package pkg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNoSocket is returned when no socket matches a connection.
+var ErrNoSocket = errors.New("pkg: no matching socket")
+
+// SocketUid returns the uid that owns the socket for the connection
+// (local, remote) of the given protocol ("tcp" or "udp"). Both the IPv4
+// and IPv6 socket tables are searched. An unconnected UDP socket or a
+// socket bound to a wildcard address matches if its port matches.
+func SocketUid(proto string, local, remote netip.AddrPort) (uint32, error) {
+	switch proto {
+	case "tcp", "udp":
+	default:
+		return 0, fmt.Errorf("pkg: unsupported protocol %q", proto)
+	}
+
+	var uid uint32
+	var found bool
+	for _, tab := range []string{proto, proto + "6"} {
+		fd, err := os.Open("/proc/net/" + tab)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, err
+		}
+
+		u, exact, err := matchSocket(fd, local, remote)
+		fd.Close()
+		if err != nil {
+			return 0, fmt.Errorf("pkg: /proc/net/%s: %w", tab, err)
+		}
+
+		switch exact {
+		case matchExact:
+			return u, nil
+		case matchWild:
+			if !found {
+				uid, found = u, true
+			}
+		}
+	}
+
+	if !found {
+		return 0, ErrNoSocket
+	}
+	return uid, nil
+}
+
+// SocketPkg returns the package that owns the connection; see SocketUid
+// and LookupUid.
+func SocketPkg(proto string, local, remote netip.AddrPort) (*Pkg, error) {
+	uid, err := SocketUid(proto, local, remote)
+	if err != nil {
+		return nil, err
+	}
+	return LookupUid(uid)
+}
+
+const (
+	matchNone = iota
+	matchWild
+	matchExact
+)
+
+// matchSocket scans a /proc/net/{tcp,udp}[6] table for the connection
+// and returns the owning uid and the quality of the match.
+func matchSocket(r io.Reader, local, remote netip.AddrPort) (uint32, int, error) {
+	local = unmap(local)
+	remote = unmap(remote)
+
+	var uid uint32
+	best := matchNone
+
+	sc := bufio.NewScanner(r)
+	sc.Scan() // header
+	for sc.Scan() {
+		v := strings.Fields(sc.Text())
+		if len(v) < 8 {
+			continue
+		}
+
+		la, err := parseProcAddr(v[1])
+		if err != nil {
+			return 0, matchNone, err
+		}
+		if la.Port() != local.Port() {
+			continue
+		}
+		ra, err := parseProcAddr(v[2])
+		if err != nil {
+			return 0, matchNone, err
+		}
+
+		var m int
+		switch {
+		case la.Addr() == local.Addr() && ra == remote:
+			m = matchExact
+		case (la.Addr() == local.Addr() || la.Addr().IsUnspecified()) && ra.Port() == 0:
+			m = matchWild
+		default:
+			continue
+		}
+
+		if m > best {
+			u, err := strconv.ParseUint(v[7], 10, 32)
+			if err != nil {
+				return 0, matchNone, fmt.Errorf("invalid uid %q", v[7])
+			}
+			uid, best = uint32(u), m
+			if m == matchExact {
+				break
+			}
+		}
+	}
+	return uid, best, sc.Err()
+}
+
+// parseProcAddr parses an "ADDR:PORT" entry of /proc/net/*. The address
+// is hex encoded as a sequence of 32-bit words in host byte order; the
+// port is hex in the usual numeric order.
+func parseProcAddr(s string) (netip.AddrPort, error) {
+	a, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.AddrPort{}, fmt.Errorf("invalid address %q", s)
+	}
+
+	b, err := hex.DecodeString(a)
+	if err != nil || (len(b) != 4 && len(b) != 16) {
+		return netip.AddrPort{}, fmt.Errorf("invalid address %q", s)
+	}
+	port, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid port %q", s)
+	}
+
+	// convert each word from host to network byte order
+	for i := 0; i < len(b); i += 4 {
+		binary.BigEndian.PutUint32(b[i:], binary.NativeEndian.Uint32(b[i:]))
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+	return unmap(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// unmap converts IPv4-mapped IPv6 addresses to plain IPv4.
+func unmap(ap netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseProcAddr(t *testing.T) {
+	// /proc/net addresses are in host byte order
+	le := binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+	tests := []struct {
+		le, be string
+		want   string
+	}{
+		{"0100007F:0050", "7F000001:0050", "127.0.0.1:80"},
+		{"00000000:1F90", "00000000:1F90", "0.0.0.0:8080"},
+		{"0000000000000000FFFF00000100007F:01BB", "0000000000000000FFFF00007F000001:01BB", "127.0.0.1:443"},
+		{"00000000000000000000000001000000:0035", "00000000000000000000000000000001:0035", "[::1]:53"},
+	}
+
+	for _, tc := range tests {
+		in := tc.be
+		if le {
+			in = tc.le
+		}
+
+		got, err := parseProcAddr(in)
+		if err != nil {
+			t.Errorf("%s: %s", in, err)
+			continue
+		}
+		if got.String() != tc.want {
+			t.Errorf("%s: want %s, got %s", in, tc.want, got)
+		}
+	}
+}
+
+func TestMatchSocket(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("sample table is little-endian")
+	}
+
+	tab := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000  1000        0 100 2
+   1: 0100007F:9C40 0100007F:0050 01 00000000:00000000 00:00000000 00000000 10123        0 101 1
+   2: 0100007F:9C40 0200007F:0050 01 00000000:00000000 00:00000000 00000000 10124        0 102 1
+`
+	tests := []struct {
+		local, remote string
+		uid           uint32
+		match         int
+	}{
+		{"127.0.0.1:40000", "127.0.0.1:80", 10123, matchExact},
+		{"127.0.0.1:40000", "127.0.0.2:80", 10124, matchExact},
+		{"10.0.0.1:53", "8.8.8.8:53", 1000, matchWild},
+		{"127.0.0.1:40001", "127.0.0.1:80", 0, matchNone},
+	}
+
+	for _, tc := range tests {
+		l := netip.MustParseAddrPort(tc.local)
+		r := netip.MustParseAddrPort(tc.remote)
+		uid, m, err := matchSocket(strings.NewReader(tab), l, r)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.local, err)
+		}
+		if uid != tc.uid || m != tc.match {
+			t.Errorf("%s -> %s: want uid %d match %d, got %d %d", tc.local, tc.remote, tc.uid, tc.match, uid, m)
+		}
+	}
+}
+
+func TestSocketUid(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %s", err)
+	}
+	defer ln.Close()
+
+	c, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer c.Close()
+
+	local := c.LocalAddr().(*net.TCPAddr).AddrPort()
+	remote := c.RemoteAddr().(*net.TCPAddr).AddrPort()
+
+	uid, err := SocketUid("tcp", local, remote)
+	if err != nil {
+		t.Fatalf("lookup: %s", err)
+	}
+	if uid != uint32(os.Getuid()) {
+		t.Errorf("want uid %d, got %d", os.Getuid(), uid)
+	}
+}
@@ -0,0 +1,133 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// WriteFlag modifies the behavior of the atomic write functions.
+type WriteFlag uint
+
+const (
+	// NoOverwrite makes the write fail if the destination exists.
+	NoOverwrite WriteFlag = 1 << iota
+
+	// NoSync skips the fsync of the file and its parent directory.
+	// The rename is still atomic but may not survive a crash.
+	NoSync
+)
+
+// WriteFileAtomic writes data to the file fn such that readers observe
+// either the old contents or the new contents, never a partial file. The
+// data is written to a temporary file in the same directory, fsync'd and
+// then renamed over fn; finally the parent directory is fsync'd so that
+// the rename itself is durable.
+func WriteFileAtomic(fn string, data []byte, mode os.FileMode, flags WriteFlag) error {
+	fd, err := createTemp(fn, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fd.Write(data); err != nil {
+		abortTemp(fd)
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return commitTemp(fd, fn, flags)
+}
+
+// createTemp creates a temporary file in the same directory as fn so that
+// it can later be renamed over fn.
+func createTemp(fn string, mode os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(fn)
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	fd, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = fd.Chmod(mode); err != nil && runtime.GOOS != "windows" {
+		abortTemp(fd)
+		return nil, err
+	}
+	return fd, nil
+}
+
+// abortTemp closes and removes a temporary file.
+func abortTemp(fd *os.File) {
+	fd.Close()
+	os.Remove(fd.Name())
+}
+
+// commitTemp syncs and closes the temporary file fd and atomically moves
+// it to fn. The temporary file is removed on failure.
+func commitTemp(fd *os.File, fn string, flags WriteFlag) error {
+	tmp := fd.Name()
+
+	if flags&NoSync == 0 {
+		if err := fd.Sync(); err != nil {
+			abortTemp(fd)
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+
+	if err := fd.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if flags&NoOverwrite != 0 {
+		// link(2) fails if the destination exists; that gives us an
+		// atomic no-clobber rename.
+		err := os.Link(tmp, fn)
+		os.Remove(tmp)
+		if err != nil {
+			return err
+		}
+	} else if err := os.Rename(tmp, fn); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if flags&NoSync == 0 {
+		return syncDir(filepath.Dir(fn))
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory so that entries created or renamed in it are
+// durable. It is a no-op on platforms that can't sync directories.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	if err = fd.Sync(); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "out")
+
+	if err := WriteFileAtomic(fn, []byte("hello"), 0640, 0); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+	if err := WriteFileAtomic(fn, []byte("world"), 0640, 0); err != nil {
+		t.Fatalf("overwrite: %s", err)
+	}
+
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if string(b) != "world" {
+		t.Errorf("want %q, got %q", "world", b)
+	}
+
+	fi, err := os.Stat(fn)
+	if err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+		t.Errorf("want mode 0640, got %o", fi.Mode().Perm())
+	}
+
+	if err := WriteFileAtomic(fn, []byte("again"), 0640, NoOverwrite); err == nil {
+		t.Errorf("NoOverwrite: expected error when %s exists", fn)
+	}
+
+	// no temporaries must be left behind
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("readdir: %s", err)
+	}
+	if len(ents) != 1 {
+		t.Errorf("want 1 dir entry, got %d", len(ents))
+	}
+}
@@ -0,0 +1,75 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"fmt"
+	"os"
+)
+
+// BackupName returns the name of the n'th backup of fn, e.g. "fn.bak.1".
+func BackupName(fn string, n int) string {
+	return fmt.Sprintf("%s.bak.%d", fn, n)
+}
+
+// Backup saves the current contents of fn as fn.bak.1 after shifting
+// older backups up by one (fn.bak.1 becomes fn.bak.2 and so on); at most
+// keep backups are retained. fn itself is left in place. It is not an
+// error if fn doesn't exist.
+func Backup(fn string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(fn); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err := os.Remove(BackupName(fn, keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for i := keep - 1; i > 0; i-- {
+		err := os.Rename(BackupName(fn, i), BackupName(fn, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// A hard link is cheap and keeps the old inode around once fn is
+	// replaced; fall back to copying on filesystems without links.
+	bak := BackupName(fn, 1)
+	if err := os.Link(fn, bak); err != nil {
+		return Copy(fn, bak, nil)
+	}
+	return nil
+}
+
+// WriteFileBackup backs up fn as described in Backup and then atomically
+// replaces it with data.
+func WriteFileBackup(fn string, data []byte, mode os.FileMode, keep int, flags WriteFlag) error {
+	if err := Backup(fn, keep); err != nil {
+		return err
+	}
+	return WriteFileAtomic(fn, data, mode, flags)
+}
+
+// Restore atomically replaces fn with its n'th backup. The backup itself
+// is left in place.
+func Restore(fn string, n int) error {
+	return Copy(BackupName(fn, n), fn, nil)
+}
@@ -0,0 +1,45 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestWriteFileBackup(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.conf")
+
+	for i := 1; i <= 5; i++ {
+		if err := WriteFileBackup(fn, []byte(strconv.Itoa(i)), 0600, 3, 0); err != nil {
+			t.Fatalf("write %d: %s", i, err)
+		}
+	}
+
+	want := map[string]string{
+		fn:                "5",
+		BackupName(fn, 1): "4",
+		BackupName(fn, 2): "3",
+		BackupName(fn, 3): "2",
+	}
+	for f, w := range want {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatalf("read %s: %s", f, err)
+		}
+		if string(b) != w {
+			t.Errorf("%s: want %q, got %q", f, w, b)
+		}
+	}
+
+	if _, err := os.Stat(BackupName(fn, 4)); !os.IsNotExist(err) {
+		t.Errorf("backup 4 should not exist: %v", err)
+	}
+
+	if err := Restore(fn, 2); err != nil {
+		t.Fatalf("restore: %s", err)
+	}
+	if b, _ := os.ReadFile(fn); string(b) != "3" {
+		t.Errorf("restore: want %q, got %q", "3", b)
+	}
+}
@@ -0,0 +1,112 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"fmt"
+	"io"
+)
+
+// Partial describes what a Chunker does with a short record at the end
+// of its input.
+type Partial int
+
+const (
+	// PartialError reports a short trailing record as
+	// io.ErrUnexpectedEOF.
+	PartialError Partial = iota
+
+	// PartialDrop silently discards a short trailing record.
+	PartialDrop
+
+	// PartialKeep returns a short trailing record as-is.
+	PartialKeep
+)
+
+// Chunker reads fixed size binary records from an io.Reader.
+type Chunker struct {
+	r      io.Reader
+	buf    []byte
+	header int64
+	policy Partial
+	n      int64
+	err    error
+}
+
+// NewChunker returns a Chunker that reads records of size bytes from r
+// after skipping a header of the given length.
+func NewChunker(r io.Reader, size int, header int64, policy Partial) (*Chunker, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("chunker: invalid record size %d", size)
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("chunker: invalid header size %d", header)
+	}
+
+	c := &Chunker{
+		r:      r,
+		buf:    make([]byte, size),
+		header: header,
+		policy: policy,
+	}
+	return c, nil
+}
+
+// Next returns the next record. The returned slice is only valid until
+// the next call to Next. It returns io.EOF when there are no more
+// records.
+func (c *Chunker) Next() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.header > 0 {
+		n, err := io.CopyN(io.Discard, c.r, c.header)
+		c.header -= n
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			c.err = fmt.Errorf("chunker: reading header: %w", err)
+			return nil, c.err
+		}
+	}
+
+	n, err := io.ReadFull(c.r, c.buf)
+	switch err {
+	case nil:
+		c.n++
+		return c.buf, nil
+
+	case io.ErrUnexpectedEOF:
+		c.err = io.EOF
+		switch c.policy {
+		case PartialKeep:
+			c.n++
+			return c.buf[:n], nil
+		case PartialDrop:
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("chunker: record %d: short record of %d bytes: %w", c.n, n, err)
+
+	default:
+		c.err = err
+		return nil, err
+	}
+}
+
+// Count returns the number of records returned so far.
+func (c *Chunker) Count() int64 {
+	return c.n
+}
@@ -0,0 +1,62 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readChunks(c *Chunker) ([]string, error) {
+	var v []string
+	for {
+		b, err := c.Next()
+		if err == io.EOF {
+			return v, nil
+		}
+		if err != nil {
+			return v, err
+		}
+		v = append(v, string(b))
+	}
+}
+
+func TestChunker(t *testing.T) {
+	in := "HDRaaaabbbbcc"
+
+	tests := []struct {
+		policy Partial
+		want   string
+		err    error
+	}{
+		{PartialKeep, "aaaa bbbb cc", nil},
+		{PartialDrop, "aaaa bbbb", nil},
+		{PartialError, "aaaa bbbb", io.ErrUnexpectedEOF},
+	}
+
+	for _, tc := range tests {
+		c, err := NewChunker(strings.NewReader(in), 4, 3, tc.policy)
+		if err != nil {
+			t.Fatalf("new: %s", err)
+		}
+
+		got, err := readChunks(c)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("policy %d: want err %v, got %v", tc.policy, tc.err, err)
+		}
+		if s := strings.Join(got, " "); s != tc.want {
+			t.Errorf("policy %d: want %q, got %q", tc.policy, tc.want, s)
+		}
+	}
+}
+
+func TestChunkerShortHeader(t *testing.T) {
+	c, _ := NewChunker(strings.NewReader("ab"), 4, 8, PartialKeep)
+	if _, err := c.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("want ErrUnexpectedEOF, got %v", err)
+	}
+
+	if _, err := NewChunker(strings.NewReader(""), 0, 0, PartialKeep); err == nil {
+		t.Errorf("expected error for zero record size")
+	}
+}
@@ -0,0 +1,186 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PresleyHank/go-lib/util"
+)
+
+// Config is a parsed ini-style file: a map of section name to the
+// key=value pairs in that section. Keys that appear before the first
+// section header belong to the section "".
+type Config map[string]map[string]string
+
+// ReadConfig parses the config file fn.
+func ReadConfig(fn string) (Config, error) {
+	fd, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	return ParseConfig(fd, fn)
+}
+
+// ParseConfig parses an ini-style config from r; name is used in error
+// messages. The syntax is:
+//
+//	# comment
+//	; comment
+//	key = value
+//	[section]
+//	key = "quoted value"
+//
+// Section and key names are case sensitive; a repeated key overrides an
+// earlier one.
+func ParseConfig(r io.Reader, name string) (Config, error) {
+	opt := LineOpt{
+		Comments: []string{"#", ";"},
+	}
+
+	c := Config{"": map[string]string{}}
+	sect := c[""]
+
+	lr := NewLineReaderOpt(r, &opt)
+	for {
+		ln, err := lr.Next()
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		s := ln.Text
+		if s[0] == '[' {
+			if s[len(s)-1] != ']' {
+				return nil, fmt.Errorf("%s:%d: malformed section header", name, ln.Num)
+			}
+			nm := strings.TrimSpace(s[1 : len(s)-1])
+			if len(nm) == 0 {
+				return nil, fmt.Errorf("%s:%d: empty section name", name, ln.Num)
+			}
+			if sect = c[nm]; sect == nil {
+				sect = map[string]string{}
+				c[nm] = sect
+			}
+			continue
+		}
+
+		i := strings.IndexByte(s, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: syntax error: expected key = value", name, ln.Num)
+		}
+
+		k := strings.TrimSpace(s[:i])
+		v := strings.TrimSpace(s[i+1:])
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			if v, err = strconv.Unquote(v); err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: bad quoted value: %w", name, ln.Num, k, err)
+			}
+		}
+		sect[k] = v
+	}
+}
+
+// Get returns the value of key in section sect.
+func (c Config) Get(sect, key string) (string, bool) {
+	v, ok := c[sect][key]
+	return v, ok
+}
+
+// String returns the value of key in section sect or def if it is not set.
+func (c Config) String(sect, key string, def string) string {
+	if v, ok := c.Get(sect, key); ok {
+		return v
+	}
+	return def
+}
+
+// Bool returns the value of key in section sect interpreted as a boolean;
+// in addition to the values accepted by strconv.ParseBool, "yes", "no",
+// "on" and "off" are understood.
+func (c Config) Bool(sect, key string, def bool) (bool, error) {
+	v, ok := c.Get(sect, key)
+	if !ok {
+		return def, nil
+	}
+
+	switch strings.ToLower(v) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("%s.%s: invalid boolean %q", sect, key, v)
+	}
+	return b, nil
+}
+
+// Int returns the value of key in section sect as an integer. The usual
+// 0x and 0 prefixes are honored.
+func (c Config) Int(sect, key string, def int64) (int64, error) {
+	v, ok := c.Get(sect, key)
+	if !ok {
+		return def, nil
+	}
+
+	n, err := strconv.ParseInt(v, 0, 64)
+	if err != nil {
+		return def, fmt.Errorf("%s.%s: invalid integer %q", sect, key, v)
+	}
+	return n, nil
+}
+
+// Duration returns the value of key in section sect as a time.Duration,
+// parsed with util.ParseDuration: besides the time.ParseDuration units it
+// accepts days, weeks, months and years, e.g. "90d" or "2w".
+func (c Config) Duration(sect, key string, def time.Duration) (time.Duration, error) {
+	v, ok := c.Get(sect, key)
+	if !ok {
+		return def, nil
+	}
+
+	d, err := util.ParseDuration(v)
+	if err != nil {
+		return def, fmt.Errorf("%s.%s: invalid duration %q", sect, key, v)
+	}
+	return d, nil
+}
+
+// Size returns the value of key in section sect as a byte count, parsed
+// with util.ParseSize: "10M" and "10MiB" are powers of 1024, "10MB" is a
+// power of 1000.
+func (c Config) Size(sect, key string, def int64) (int64, error) {
+	v, ok := c.Get(sect, key)
+	if !ok {
+		return def, nil
+	}
+
+	n, err := util.ParseSize(v)
+	if err != nil {
+		return def, fmt.Errorf("%s.%s: invalid size %q", sect, key, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package fileio
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+# global settings
+name = server
+; ini style comment
+
+[log]
+level   = debug
+rotate  = yes
+keep    = 0x0e
+maxsize = 10MiB
+minsize = 10MB
+every   = 24h
+expire  = 90d
+grace   = 2w 12h
+banner  = "  spaces kept  "
+
+[empty]
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader(testConfig), "test.conf")
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	if v := c.String("", "name", ""); v != "server" {
+		t.Errorf("name: want server, got %q", v)
+	}
+	if v := c.String("log", "banner", ""); v != "  spaces kept  " {
+		t.Errorf("banner: got %q", v)
+	}
+	if _, ok := c["empty"]; !ok {
+		t.Errorf("section 'empty' missing")
+	}
+
+	if b, err := c.Bool("log", "rotate", false); err != nil || !b {
+		t.Errorf("rotate: want true, got %v %v", b, err)
+	}
+	if n, err := c.Int("log", "keep", 0); err != nil || n != 14 {
+		t.Errorf("keep: want 14, got %d %v", n, err)
+	}
+	if n, err := c.Size("log", "maxsize", 0); err != nil || n != 10<<20 {
+		t.Errorf("maxsize: want %d, got %d %v", 10<<20, n, err)
+	}
+	if n, err := c.Size("log", "minsize", 0); err != nil || n != 10e6 {
+		t.Errorf("minsize: want %d, got %d %v", int64(10e6), n, err)
+	}
+	if d, err := c.Duration("log", "every", 0); err != nil || d != 24*time.Hour {
+		t.Errorf("every: want 24h, got %s %v", d, err)
+	}
+	if d, err := c.Duration("log", "expire", 0); err != nil || d != 90*24*time.Hour {
+		t.Errorf("expire: want 90d, got %s %v", d, err)
+	}
+	if d, err := c.Duration("log", "grace", 0); err != nil || d != (14*24+12)*time.Hour {
+		t.Errorf("grace: want 2w12h, got %s %v", d, err)
+	}
+	if _, err := c.Duration("log", "level", 0); err == nil {
+		t.Errorf("level: expected duration error")
+	}
+	if n, err := c.Int("log", "missing", 7); err != nil || n != 7 {
+		t.Errorf("missing: want default 7, got %d %v", n, err)
+	}
+	if _, err := c.Int("log", "level", 0); err == nil {
+		t.Errorf("level: expected conversion error")
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"a = 1\n[sect\n", "x.conf:2: malformed section header"},
+		{"\n\njunk\n", "x.conf:3: syntax error"},
+		{"[]\n", "x.conf:1: empty section name"},
+	}
+
+	for _, tc := range tests {
+		_, err := ParseConfig(strings.NewReader(tc.in), "x.conf")
+		if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%q: want error %q, got %v", tc.in, tc.want, err)
+		}
+	}
+}
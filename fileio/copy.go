@@ -0,0 +1,111 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrChecksum is returned by Copy when the copied data doesn't match
+// the source.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// CopyOpt controls Copy.
+type CopyOpt struct {
+	// Verify re-reads the copy before committing it and compares its
+	// SHA-256 checksum against that of the source.
+	Verify bool
+
+	// Flags are passed on to the atomic commit of the destination.
+	Flags WriteFlag
+}
+
+// Copy copies the regular file src to dst, preserving its permission bits
+// and modification time. The destination is written atomically: it either
+// has the old contents or a complete copy of src.
+func Copy(src, dst string, opt *CopyOpt) error {
+	var o CopyOpt
+	if opt != nil {
+		o = *opt
+	}
+
+	sfd, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sfd.Close()
+
+	fi, err := sfd.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("copy %s: not a regular file", src)
+	}
+
+	fd, err := createTemp(dst, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err = io.Copy(fd, io.TeeReader(sfd, h)); err != nil {
+		abortTemp(fd)
+		return fmt.Errorf("copy %s to %s: %w", src, dst, err)
+	}
+
+	if o.Verify {
+		if err = verifyCopy(fd, h.Sum(nil)); err != nil {
+			abortTemp(fd)
+			return fmt.Errorf("copy %s to %s: %w", src, dst, err)
+		}
+	}
+
+	mtime := fi.ModTime()
+	if err = os.Chtimes(fd.Name(), mtime, mtime); err != nil {
+		abortTemp(fd)
+		return err
+	}
+
+	return commitTemp(fd, dst, o.Flags)
+}
+
+// verifyCopy flushes fd to stable storage and compares the checksum of
+// its contents against sum.
+func verifyCopy(fd *os.File, sum []byte) error {
+	if err := fd.Sync(); err != nil {
+		return err
+	}
+
+	rfd, err := os.Open(fd.Name())
+	if err != nil {
+		return err
+	}
+	defer rfd.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, rfd); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return ErrChecksum
+	}
+	return nil
+}
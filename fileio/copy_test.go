@@ -0,0 +1,58 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	data := make([]byte, 256*1024)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	if err := os.WriteFile(src, data, 0640); err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %s", err)
+	}
+
+	if err := Copy(src, dst, &CopyOpt{Verify: true}); err != nil {
+		t.Fatalf("copy: %s", err)
+	}
+
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if string(b) != string(data) {
+		t.Errorf("contents differ")
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("stat: %s", err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("want mtime %s, got %s", mtime, fi.ModTime())
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+		t.Errorf("want mode 0640, got %o", fi.Mode().Perm())
+	}
+
+	if err := Copy(src, dst, &CopyOpt{Flags: NoOverwrite}); err == nil {
+		t.Errorf("NoOverwrite: expected error")
+	}
+	if err := Copy(dir, dst, nil); err == nil {
+		t.Errorf("expected error copying a directory")
+	}
+}
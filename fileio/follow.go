@@ -0,0 +1,234 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Follower yields lines as they are appended to a file, in the manner of
+// "tail -F". A truncated file is re-read from the beginning and a file
+// that is renamed or replaced (e.g. by log rotation) is reopened by name.
+type Follower struct {
+	name string
+	poll time.Duration
+
+	fd   *os.File
+	rd   *bufio.Reader
+	fi   os.FileInfo
+	off  int64
+	part string
+
+	ch   chan string
+	done chan struct{}
+	wg   sync.WaitGroup
+	err  error
+	once sync.Once
+}
+
+// Follow starts following the file fn, checking it for new data every
+// poll interval. If fromStart is false, only lines appended after the call
+// are returned.
+func Follow(fn string, poll time.Duration, fromStart bool) (*Follower, error) {
+	f, err := openFollower(fn, poll, fromStart)
+	if err != nil {
+		return nil, err
+	}
+
+	f.wg.Add(1)
+	go f.run()
+	return f, nil
+}
+
+// openFollower sets up a follower without starting its goroutine.
+func openFollower(fn string, poll time.Duration, fromStart bool) (*Follower, error) {
+	fd, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	f := &Follower{
+		name: fn,
+		poll: poll,
+		fd:   fd,
+		rd:   bufio.NewReader(fd),
+		fi:   fi,
+		ch:   make(chan string, 16),
+		done: make(chan struct{}),
+	}
+
+	if !fromStart {
+		if f.off, err = fd.Seek(0, io.SeekEnd); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Lines returns the channel on which new lines are delivered. The
+// channel is closed when the follower is closed or hits an I/O error.
+func (f *Follower) Lines() <-chan string {
+	return f.ch
+}
+
+// Err returns the error that stopped the follower, if any.
+func (f *Follower) Err() error {
+	return f.err
+}
+
+// Close stops following the file and releases its resources.
+func (f *Follower) Close() error {
+	f.once.Do(func() {
+		close(f.done)
+	})
+	f.wg.Wait()
+	return nil
+}
+
+func (f *Follower) run() {
+	defer func() {
+		f.fd.Close()
+		close(f.ch)
+		f.wg.Done()
+	}()
+
+	t := time.NewTicker(f.poll)
+	defer t.Stop()
+
+	for {
+		ok, err := f.drain()
+		if err != nil {
+			f.err = err
+			return
+		}
+		if !ok {
+			return
+		}
+
+		if err := f.check(); err != nil {
+			f.err = err
+			return
+		}
+
+		select {
+		case <-f.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// drain sends every complete line available in the current file. It
+// returns false if the follower was closed, and any read error other
+// than reaching the end of the file.
+func (f *Follower) drain() (bool, error) {
+	for {
+		s, err := f.rd.ReadString('\n')
+		f.off += int64(len(s))
+		if err == io.EOF {
+			// incomplete line; wait for the rest of it
+			f.part += s
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		s = strings.TrimRight(f.part+s, "\r\n")
+		f.part = ""
+		if !f.send(s) {
+			return false, nil
+		}
+	}
+}
+
+func (f *Follower) send(s string) bool {
+	select {
+	case f.ch <- s:
+		return true
+	case <-f.done:
+		return false
+	}
+}
+
+// check detects truncation and rotation of the file being followed.
+func (f *Follower) check() error {
+	fi, err := os.Stat(f.name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// rotated away and not yet recreated
+			return nil
+		}
+		return err
+	}
+
+	if !os.SameFile(fi, f.fi) {
+		fd, err := os.Open(f.name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		// finish the old file first: lines written just before the
+		// rotation are still there, and so is an unterminated last line
+		ok, err := f.drain()
+		if err != nil {
+			fd.Close()
+			return err
+		}
+		if ok && f.part != "" {
+			ok = f.send(strings.TrimRight(f.part, "\r"))
+		}
+		if !ok {
+			fd.Close()
+			return nil
+		}
+
+		f.fd.Close()
+		f.fd = fd
+		f.fi = fi
+		f.rd.Reset(fd)
+		f.off = 0
+		f.part = ""
+		return nil
+	}
+
+	if fi.Size() < f.off {
+		if _, err := f.fd.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		f.rd.Reset(f.fd)
+		f.off = 0
+		f.part = ""
+	}
+	return nil
+}
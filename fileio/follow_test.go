@@ -0,0 +1,156 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func appendFile(t *testing.T, fn, s string) {
+	fd, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatalf("open %s: %s", fn, err)
+	}
+	if _, err := fd.WriteString(s); err != nil {
+		t.Fatalf("write %s: %s", fn, err)
+	}
+	fd.Close()
+}
+
+func expectLines(t *testing.T, f *Follower, want ...string) {
+	for _, w := range want {
+		select {
+		case got, ok := <-f.Lines():
+			if !ok {
+				t.Fatalf("follower closed: %v", f.Err())
+			}
+			if got != w {
+				t.Fatalf("want %q, got %q", w, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "app.log")
+
+	appendFile(t, fn, "old line\n")
+
+	f, err := Follow(fn, 5*time.Millisecond, false)
+	if err != nil {
+		t.Fatalf("follow: %s", err)
+	}
+	defer f.Close()
+
+	appendFile(t, fn, "one\ntw")
+	appendFile(t, fn, "o\n")
+	expectLines(t, f, "one", "two")
+
+	// truncation
+	if err := os.Truncate(fn, 0); err != nil {
+		t.Fatalf("truncate: %s", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, fn, "three\n")
+	expectLines(t, f, "three")
+
+	// rotation
+	if err := os.Rename(fn, fn+".1"); err != nil {
+		t.Fatalf("rename: %s", err)
+	}
+	appendFile(t, fn, "four\n")
+	expectLines(t, f, "four")
+
+	f.Close()
+	if _, ok := <-f.Lines(); ok {
+		t.Errorf("channel not closed after Close")
+	}
+}
+
+func TestFollowFromStart(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "x")
+	appendFile(t, fn, "a\nb\n")
+
+	f, err := Follow(fn, 5*time.Millisecond, true)
+	if err != nil {
+		t.Fatalf("follow: %s", err)
+	}
+	defer f.Close()
+
+	expectLines(t, f, "a", "b")
+}
+
+func TestFollowRotateDrain(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, fn, "a\n")
+
+	// no goroutine: drain and check are driven by hand so the writes
+	// land between them, as they can in the run loop
+	f, err := openFollower(fn, time.Hour, true)
+	if err != nil {
+		t.Fatalf("follow: %s", err)
+	}
+	defer f.fd.Close()
+
+	f.drain()
+	appendFile(t, fn, "b\nc")
+	if err := os.Rename(fn, fn+".1"); err != nil {
+		t.Fatalf("rename: %s", err)
+	}
+	appendFile(t, fn, "d\n")
+
+	if err := f.check(); err != nil {
+		t.Fatalf("check: %s", err)
+	}
+	f.drain()
+
+	for _, w := range []string{"a", "b", "c", "d"} {
+		select {
+		case got := <-f.ch:
+			if got != w {
+				t.Fatalf("want %q, got %q", w, got)
+			}
+		default:
+			t.Fatalf("missing %q", w)
+		}
+	}
+}
+
+func TestFollowReadError(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, fn, "")
+
+	f, err := openFollower(fn, 10*time.Millisecond, true)
+	if err != nil {
+		t.Fatalf("follow: %s", err)
+	}
+
+	// a read that fails outright is reported, not retried as a partial
+	// line
+	errIO := errors.New("input/output error")
+	f.rd.Reset(io.MultiReader(strings.NewReader("a\n"), iotest.ErrReader(errIO)))
+	f.wg.Add(1)
+	go f.run()
+	defer f.Close()
+
+	expectLines(t, f, "a")
+	select {
+	case _, ok := <-f.Lines():
+		if ok {
+			t.Fatalf("unexpected line")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("follower still running after a read error")
+	}
+	if f.Err() != errIO {
+		t.Errorf("want the read error, got %v", f.Err())
+	}
+}
@@ -0,0 +1,221 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package fileio provides helpers for reading and writing text and binary files.
+package fileio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrLineTooLong is returned by LineReader when a line exceeds the
+// configured maximum length.
+var ErrLineTooLong = errors.New("line too long")
+
+// Line is a single line of input along with its 1-based line number in
+// the underlying stream.
+type Line struct {
+	Text string
+	Num  int
+}
+
+// LineOpt describes the dialect of a line oriented file.
+type LineOpt struct {
+	// Comments is the list of prefixes that start a comment, e.g.
+	// "#", "//" or ";". An empty list disables comment handling.
+	Comments []string
+
+	// Inline strips comments that start in the middle of a line;
+	// otherwise only lines that begin with a comment prefix are
+	// treated as comments.
+	Inline bool
+
+	// KeepSpace preserves leading and trailing whitespace.
+	KeepSpace bool
+
+	// KeepBlank returns blank lines instead of skipping them.
+	KeepBlank bool
+
+	// MaxLen is the maximum length of a line in bytes, excluding the
+	// line terminator. Zero means lines can be arbitrarily long.
+	MaxLen int
+}
+
+// defaultLineOpt is the classic shell/config dialect: '#' comments,
+// whitespace trimmed and blank lines skipped.
+var defaultLineOpt = LineOpt{
+	Comments: []string{"#"},
+}
+
+// LineReader reads lines from an io.Reader and keeps track of line
+// numbers so that callers can report errors as "file:line".
+type LineReader struct {
+	rd  *bufio.Reader
+	buf []byte
+	num int
+	opt LineOpt
+}
+
+// NewLineReader returns a LineReader that reads from r. Leading and
+// trailing whitespace is trimmed; blank lines and lines starting with '#'
+// are skipped.
+func NewLineReader(r io.Reader) *LineReader {
+	return NewLineReaderOpt(r, nil)
+}
+
+// NewLineReaderOpt returns a LineReader that reads from r and interprets
+// the input as described by opt. A nil opt is the same as NewLineReader().
+func NewLineReaderOpt(r io.Reader, opt *LineOpt) *LineReader {
+	lr := &LineReader{
+		rd:  bufio.NewReader(r),
+		opt: defaultLineOpt,
+	}
+	if opt != nil {
+		lr.opt = *opt
+	}
+	return lr
+}
+
+// Next returns the next line of input. It returns io.EOF when there are
+// no more lines. A line longer than the configured maximum is discarded
+// and reported as an error wrapping ErrLineTooLong; reading can continue
+// with the following line.
+func (lr *LineReader) Next() (Line, error) {
+	for {
+		s, long, err := lr.readLine()
+		if len(s) == 0 && !long && err != nil {
+			return Line{}, err
+		}
+
+		lr.num++
+		if long {
+			return Line{Num: lr.num}, fmt.Errorf("line %d: %w", lr.num, ErrLineTooLong)
+		}
+
+		s, ok := lr.clean(s)
+		if !ok {
+			if err != nil {
+				return Line{}, err
+			}
+			continue
+		}
+
+		return Line{Text: s, Num: lr.num}, nil
+	}
+}
+
+// readLine reads one raw line (including its terminator) into a buffer
+// that grows as needed. If the line exceeds the maximum length, the rest
+// of it is consumed without being buffered and long is set.
+func (lr *LineReader) readLine() (s string, long bool, err error) {
+	max := lr.opt.MaxLen
+
+	lr.buf = lr.buf[:0]
+	for {
+		var frag []byte
+
+		frag, err = lr.rd.ReadSlice('\n')
+		if !long {
+			lr.buf = append(lr.buf, frag...)
+
+			// allow room for a "\r\n" terminator
+			if max > 0 && len(lr.buf) > max+2 {
+				long = true
+				lr.buf = lr.buf[:0]
+			}
+		}
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+
+	if long {
+		return "", true, err
+	}
+
+	s = string(lr.buf)
+	if max > 0 && len(strings.TrimRight(s, "\r\n")) > max {
+		return "", true, err
+	}
+	return s, false, err
+}
+
+// clean applies the reader's dialect to a raw line and returns false if
+// the line must be skipped.
+func (lr *LineReader) clean(s string) (string, bool) {
+	o := &lr.opt
+
+	s = strings.TrimRight(s, "\r\n")
+	if isComment(strings.TrimLeft(s, " \t"), o.Comments) {
+		return "", false
+	}
+
+	if o.Inline {
+		s = stripComment(s, o.Comments)
+	}
+	if !o.KeepSpace {
+		s = strings.TrimSpace(s)
+	}
+
+	if !o.KeepBlank && len(strings.TrimSpace(s)) == 0 {
+		return "", false
+	}
+	return s, true
+}
+
+// isComment returns true if s starts with any of the comment prefixes.
+func isComment(s string, pref []string) bool {
+	for _, p := range pref {
+		if len(p) > 0 && strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripComment removes everything starting at the earliest comment
+// prefix in s.
+func stripComment(s string, pref []string) string {
+	n := len(s)
+	for _, p := range pref {
+		if len(p) == 0 {
+			continue
+		}
+		if i := strings.Index(s[:n], p); i >= 0 {
+			n = i
+		}
+	}
+	return s[:n]
+}
+
+// Readlines calls fp for every line read from r; it stops at the first
+// error returned by fp.
+func Readlines(r io.Reader, fp func(Line) error) error {
+	lr := NewLineReader(r)
+	for {
+		ln, err := lr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fp(ln); err != nil {
+			return err
+		}
+	}
+}
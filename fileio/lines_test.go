@@ -0,0 +1,140 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	in := "# comment\n  first  \n\nsecond\n   # indented comment\nthird"
+	want := []Line{
+		{"first", 2},
+		{"second", 4},
+		{"third", 6},
+	}
+
+	lr := NewLineReader(strings.NewReader(in))
+	for i, w := range want {
+		got, err := lr.Next()
+		if err != nil {
+			t.Fatalf("line %d: unexpected error: %s", i, err)
+		}
+		if got != w {
+			t.Errorf("line %d: want %+v, got %+v", i, w, got)
+		}
+	}
+
+	if _, err := lr.Next(); err != io.EOF {
+		t.Errorf("want io.EOF, got %v", err)
+	}
+}
+
+func TestReadlines(t *testing.T) {
+	in := "a\nb\nc\n"
+	errStop := errors.New("stop")
+
+	var got []string
+	err := Readlines(strings.NewReader(in), func(ln Line) error {
+		if ln.Text == "c" {
+			return errStop
+		}
+		got = append(got, ln.Text)
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("want %v, got %v", errStop, err)
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Errorf("want a,b, got %v", got)
+	}
+}
+
+func TestLineReaderOpt(t *testing.T) {
+	in := "; ini comment\n// c++ comment\nkey = val ; trailing\n\n  indented  \n"
+
+	tests := []struct {
+		opt  LineOpt
+		want []string
+	}{
+		{
+			LineOpt{Comments: []string{";", "//"}},
+			[]string{"key = val ; trailing", "indented"},
+		},
+		{
+			LineOpt{Comments: []string{";", "//"}, Inline: true},
+			[]string{"key = val", "indented"},
+		},
+		{
+			LineOpt{Comments: []string{";"}, KeepSpace: true, KeepBlank: true},
+			[]string{"// c++ comment", "key = val ; trailing", "", "  indented  "},
+		},
+		{
+			LineOpt{},
+			[]string{"; ini comment", "// c++ comment", "key = val ; trailing", "indented"},
+		},
+	}
+
+	for i, tc := range tests {
+		var got []string
+		lr := NewLineReaderOpt(strings.NewReader(in), &tc.opt)
+		for {
+			ln, err := lr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%d: unexpected error: %s", i, err)
+			}
+			got = append(got, ln.Text)
+		}
+
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestLineReaderLong(t *testing.T) {
+	huge := strings.Repeat("x", 100000)
+	in := "short\n" + huge + "\n" + strings.Repeat("y", 11) + "\r\nend\n"
+
+	// unlimited
+	lr := NewLineReader(strings.NewReader(in))
+	lr.Next()
+	ln, err := lr.Next()
+	if err != nil || ln.Text != huge {
+		t.Fatalf("long line: err %v, len %d", err, len(ln.Text))
+	}
+
+	// bounded
+	lr = NewLineReaderOpt(strings.NewReader(in), &LineOpt{MaxLen: 10})
+	want := []struct {
+		text string
+		num  int
+		err  error
+	}{
+		{"short", 1, nil},
+		{"", 2, ErrLineTooLong},
+		{"", 3, ErrLineTooLong},
+		{"end", 4, nil},
+		{"", 0, io.EOF},
+	}
+
+	for i, w := range want {
+		ln, err := lr.Next()
+		if !errors.Is(err, w.err) {
+			t.Fatalf("%d: want err %v, got %v", i, w.err, err)
+		}
+		if ln.Text != w.text || ln.Num != w.num {
+			t.Errorf("%d: want %q@%d, got %q@%d", i, w.text, w.num, ln.Text, ln.Num)
+		}
+	}
+
+	// exactly at the limit
+	lr = NewLineReaderOpt(strings.NewReader("0123456789\r\n"), &LineOpt{MaxLen: 10})
+	if ln, err := lr.Next(); err != nil || ln.Text != "0123456789" {
+		t.Errorf("limit: want 0123456789, got %q %v", ln.Text, err)
+	}
+}
@@ -0,0 +1,191 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrClosed is returned when writing to a closed LineWriter.
+var ErrClosed = errors.New("writer closed")
+
+// LineWriter is a buffered, newline terminated text writer. It is the
+// writing counterpart of LineReader.
+//
+// A LineWriter created by NewLineWriter writes to a temporary file that
+// atomically replaces the destination on Close; Abort discards it. A
+// LineWriter created by NewLineAppender appends to the destination and
+// fsyncs it periodically.
+type LineWriter struct {
+	fd    *os.File
+	bw    *bufio.Writer
+	fn    string
+	flags WriteFlag
+
+	atomic bool
+	every  int
+	n      int
+	err    error
+}
+
+// NewLineWriter creates a LineWriter whose output replaces fn atomically
+// when the writer is closed.
+func NewLineWriter(fn string, mode os.FileMode, flags WriteFlag) (*LineWriter, error) {
+	fd, err := createTemp(fn, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LineWriter{
+		fd:     fd,
+		bw:     bufio.NewWriter(fd),
+		fn:     fn,
+		flags:  flags,
+		atomic: true,
+	}
+	return w, nil
+}
+
+// NewLineAppender creates a LineWriter that appends to fn, creating it
+// with mode if needed. The file is fsync'd after every syncEvery lines
+// and on Close; a syncEvery of zero only syncs on Close.
+func NewLineAppender(fn string, mode os.FileMode, syncEvery int) (*LineWriter, error) {
+	fd, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LineWriter{
+		fd:    fd,
+		bw:    bufio.NewWriter(fd),
+		fn:    fn,
+		every: syncEvery,
+	}
+	return w, nil
+}
+
+// Write writes b verbatim; it implements io.Writer.
+func (w *LineWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, err := w.bw.Write(b)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// WriteLine writes s followed by a newline unless s already ends in one.
+func (w *LineWriter) WriteLine(s string) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if _, err := w.bw.WriteString(s); err != nil {
+		w.err = err
+		return err
+	}
+	if !strings.HasSuffix(s, "\n") {
+		if err := w.bw.WriteByte('\n'); err != nil {
+			w.err = err
+			return err
+		}
+	}
+
+	w.n++
+	if w.every > 0 && w.n%w.every == 0 {
+		return w.sync()
+	}
+	return nil
+}
+
+// Printf formats according to a format specifier and writes the result
+// as a line.
+func (w *LineWriter) Printf(format string, v ...interface{}) error {
+	return w.WriteLine(fmt.Sprintf(format, v...))
+}
+
+// Lines returns the number of lines written so far.
+func (w *LineWriter) Lines() int {
+	return w.n
+}
+
+// Close flushes buffered data and commits the file. For an atomic writer,
+// the destination is left untouched if any earlier write failed.
+func (w *LineWriter) Close() error {
+	if w.err == ErrClosed {
+		return w.err
+	}
+
+	// abort overwrites w.err; report the write error instead
+	if err := w.err; err != nil {
+		w.abort()
+		return err
+	}
+
+	if err := w.bw.Flush(); err != nil {
+		w.abort()
+		return fmt.Errorf("%s: %w", w.fn, err)
+	}
+
+	w.err = ErrClosed
+	if w.atomic {
+		return commitTemp(w.fd, w.fn, w.flags)
+	}
+
+	err := w.fd.Sync()
+	if e := w.fd.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Abort discards everything written to an atomic writer and leaves the
+// destination untouched. For an appending writer it is the same as Close.
+func (w *LineWriter) Abort() error {
+	if !w.atomic {
+		return w.Close()
+	}
+	if w.err != ErrClosed {
+		w.abort()
+	}
+	return nil
+}
+
+func (w *LineWriter) abort() {
+	if w.atomic {
+		abortTemp(w.fd)
+	} else {
+		w.fd.Close()
+	}
+	w.err = ErrClosed
+}
+
+func (w *LineWriter) sync() error {
+	if err := w.bw.Flush(); err != nil {
+		w.err = err
+		return err
+	}
+	if err := w.fd.Sync(); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package fileio
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineWriterAtomic(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(fn, []byte("orig\n"), 0600); err != nil {
+		t.Fatalf("setup: %s", err)
+	}
+
+	w, err := NewLineWriter(fn, 0600, 0)
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	w.WriteLine("one")
+	w.WriteLine("two\n")
+	w.Printf("%d", 3)
+
+	// destination untouched until Close
+	if b, _ := os.ReadFile(fn); string(b) != "orig\n" {
+		t.Errorf("before close: got %q", b)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	if b, _ := os.ReadFile(fn); string(b) != "one\ntwo\n3\n" {
+		t.Errorf("after close: got %q", b)
+	}
+	if w.Lines() != 3 {
+		t.Errorf("want 3 lines, got %d", w.Lines())
+	}
+	if err := w.WriteLine("late"); err != ErrClosed {
+		t.Errorf("write after close: want ErrClosed, got %v", err)
+	}
+
+	// abort leaves the file alone
+	w, err = NewLineWriter(fn, 0600, 0)
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	w.WriteLine("discarded")
+	w.Abort()
+	if b, _ := os.ReadFile(fn); string(b) != "one\ntwo\n3\n" {
+		t.Errorf("after abort: got %q", b)
+	}
+
+	ents, _ := os.ReadDir(filepath.Dir(fn))
+	if len(ents) != 1 {
+		t.Errorf("temporary files left behind: %d entries", len(ents))
+	}
+}
+
+func TestLineAppender(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "log")
+
+	for _, s := range []string{"a", "b"} {
+		w, err := NewLineAppender(fn, 0600, 1)
+		if err != nil {
+			t.Fatalf("open: %s", err)
+		}
+		w.WriteLine(s)
+
+		// synced after every line
+		if b, _ := os.ReadFile(fn); len(b) == 0 {
+			t.Errorf("line %q not synced", s)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close: %s", err)
+		}
+	}
+
+	if b, _ := os.ReadFile(fn); string(b) != "a\nb\n" {
+		t.Errorf("want %q, got %q", "a\nb\n", b)
+	}
+}
+
+type failWriter struct{}
+
+var errFailWriter = errors.New("write failed")
+
+func (failWriter) Write(b []byte) (int, error) {
+	return 0, errFailWriter
+}
+
+func TestLineWriterError(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(fn, []byte("orig\n"), 0600); err != nil {
+		t.Fatalf("setup: %s", err)
+	}
+
+	w, err := NewLineWriter(fn, 0600, 0)
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	w.bw = bufio.NewWriterSize(failWriter{}, 16)
+
+	w.WriteLine(strings.Repeat("x", 32))
+	if err := w.Close(); err != errFailWriter {
+		t.Errorf("close: want the write error, got %v", err)
+	}
+	if err := w.Close(); err != ErrClosed {
+		t.Errorf("second close: want ErrClosed, got %v", err)
+	}
+	if b, _ := os.ReadFile(fn); string(b) != "orig\n" {
+		t.Errorf("destination changed: %q", b)
+	}
+}
@@ -0,0 +1,78 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"io"
+	"os"
+)
+
+// FileLine is a line annotated with the name of the file it came from. If
+// Err is non-nil, it is the last value sent and describes why reading
+// stopped.
+type FileLine struct {
+	Line
+	File string
+	Err  error
+}
+
+// GenlinesMulti streams the lines of each of the named files in order, as
+// cat(1) would; the name "-" stands for standard input. Lines are
+// delivered verbatim (minus the line terminator) including blank lines.
+// Line numbers restart at 1 for each file. The channel is closed after
+// the last line or the first error; callers must drain it.
+func GenlinesMulti(paths ...string) <-chan FileLine {
+	ch := make(chan FileLine, 16)
+
+	go func() {
+		defer close(ch)
+
+		for _, fn := range paths {
+			if err := genlines(ch, fn); err != nil {
+				ch <- FileLine{File: fn, Err: err}
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func genlines(ch chan<- FileLine, fn string) error {
+	var fd io.Reader = os.Stdin
+	if fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fd = f
+	}
+
+	opt := LineOpt{
+		KeepSpace: true,
+		KeepBlank: true,
+	}
+
+	lr := NewLineReaderOpt(fd, &opt)
+	for {
+		ln, err := lr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ch <- FileLine{Line: ln, File: fn}
+	}
+}
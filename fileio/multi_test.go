@@ -0,0 +1,46 @@
+package fileio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenlinesMulti(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	os.WriteFile(a, []byte("a1\n\n  a3\n"), 0600)
+	os.WriteFile(b, []byte("# b1\nb2"), 0600)
+
+	var got []string
+	for fl := range GenlinesMulti(a, b) {
+		if fl.Err != nil {
+			t.Fatalf("unexpected error: %s", fl.Err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d:%s", filepath.Base(fl.File), fl.Num, fl.Text))
+	}
+
+	want := "a:1:a1|a:2:|a:3:  a3|b:1:# b1|b:2:b2"
+	if s := strings.Join(got, "|"); s != want {
+		t.Errorf("want %q, got %q", want, s)
+	}
+}
+
+func TestGenlinesMultiMissing(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	os.WriteFile(a, []byte("x\n"), 0600)
+
+	var n int
+	var last FileLine
+	for fl := range GenlinesMulti(a, filepath.Join(dir, "nope"), a) {
+		n++
+		last = fl
+	}
+	if n != 2 || last.Err == nil {
+		t.Errorf("want 1 line then an error, got %d values, last %+v", n, last)
+	}
+}
@@ -0,0 +1,66 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// Record is one delimiter separated record along with the line number on
+// which it starts. If Err is non-nil the record is the last one sent and
+// Err describes why reading stopped.
+type Record struct {
+	Fields []string
+	Num    int
+	Err    error
+}
+
+// Genrecords reads delimiter separated records from r (e.g. ',' for CSV or
+// '\t' for TSV) and sends them on the returned channel. Fields may be
+// quoted with '"', lines starting with '#' are skipped and records can
+// have a varying number of fields. The channel is closed at EOF or after
+// a record carrying a parse error; callers must drain it.
+func Genrecords(r io.Reader, delim rune) <-chan Record {
+	ch := make(chan Record, 16)
+
+	go func() {
+		defer close(ch)
+
+		cr := csv.NewReader(r)
+		cr.Comma = delim
+		cr.Comment = '#'
+		cr.FieldsPerRecord = -1
+
+		for {
+			v, err := cr.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var n int
+				if pe, ok := err.(*csv.ParseError); ok {
+					n = pe.Line
+				}
+				ch <- Record{Num: n, Err: err}
+				return
+			}
+
+			n, _ := cr.FieldPos(0)
+			ch <- Record{Fields: v, Num: n}
+		}
+	}()
+
+	return ch
+}
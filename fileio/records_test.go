@@ -0,0 +1,63 @@
+package fileio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenrecords(t *testing.T) {
+	in := "# name,value\na,1\n\n\"b, quoted\",\"multi\nline\"\nc\t3\n"
+
+	want := []Record{
+		{Fields: []string{"a", "1"}, Num: 2},
+		{Fields: []string{"b, quoted", "multi\nline"}, Num: 4},
+		{Fields: []string{"c\t3"}, Num: 6},
+	}
+
+	var got []Record
+	for r := range Genrecords(strings.NewReader(in), ',') {
+		if r.Err != nil {
+			t.Fatalf("unexpected error: %s", r.Err)
+		}
+		got = append(got, r)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("want %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		w, g := want[i], got[i]
+		if g.Num != w.Num || strings.Join(g.Fields, "|") != strings.Join(w.Fields, "|") {
+			t.Errorf("record %d: want %+v, got %+v", i, w, g)
+		}
+	}
+}
+
+func TestGenrecordsTSV(t *testing.T) {
+	in := "x\ty\tz\n1\t2\n"
+	var n int
+	for r := range Genrecords(strings.NewReader(in), '\t') {
+		if r.Err != nil {
+			t.Fatalf("unexpected error: %s", r.Err)
+		}
+		n += len(r.Fields)
+	}
+	if n != 5 {
+		t.Errorf("want 5 fields, got %d", n)
+	}
+}
+
+func TestGenrecordsError(t *testing.T) {
+	in := "a,b\nc,\"unterminated\n"
+
+	var last Record
+	for r := range Genrecords(strings.NewReader(in), ',') {
+		last = r
+	}
+	if last.Err == nil {
+		t.Fatalf("expected parse error")
+	}
+	if last.Num != 2 {
+		t.Errorf("want error at line 2, got %d", last.Num)
+	}
+}
@@ -0,0 +1,258 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fileio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// WalkOpt controls a directory walk.
+type WalkOpt struct {
+	// Include is a list of glob patterns (as understood by
+	// filepath.Match) matched against the base name of each file. If
+	// empty, all files are included.
+	Include []string
+
+	// Exclude is a list of glob patterns matched against the base name
+	// of files and directories; excluded directories are not descended.
+	Exclude []string
+
+	// MaxDepth limits how deep the walk descends; entries directly
+	// under a root are at depth 1. Zero means no limit.
+	MaxDepth int
+
+	// FollowSymlinks makes the walker descend into symlinked
+	// directories and report the target of symlinked files. Otherwise
+	// symlinks are reported as-is.
+	FollowSymlinks bool
+
+	// Concurrency is the number of directories processed in parallel;
+	// it defaults to the number of CPUs.
+	Concurrency int
+}
+
+// WalkEntry is a single non-directory entry found during a walk.
+type WalkEntry struct {
+	Path  string
+	Info  os.FileInfo
+	Depth int
+}
+
+// Walk traverses the trees rooted at roots in parallel and calls fp for
+// every file (i.e. non-directory) selected by opt. fp is called
+// concurrently from multiple goroutines. The walk stops at the first
+// error, either from the filesystem or returned by fp, and returns it.
+func Walk(roots []string, opt *WalkOpt, fp func(WalkEntry) error) error {
+	var o WalkOpt
+	if opt != nil {
+		o = *opt
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = runtime.NumCPU()
+	}
+
+	for _, pats := range [][]string{o.Include, o.Exclude} {
+		for _, p := range pats {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return fmt.Errorf("walk: %q: %w", p, err)
+			}
+		}
+	}
+
+	w := &walker{
+		WalkOpt: o,
+		fp:      fp,
+		sem:     make(chan struct{}, o.Concurrency),
+		seen:    make(map[string]bool),
+	}
+
+	for _, r := range roots {
+		fi, err := w.stat(r)
+		if err != nil {
+			w.fail(err)
+			break
+		}
+
+		if !fi.IsDir() {
+			if w.included(filepath.Base(r)) {
+				if err = fp(WalkEntry{Path: r, Info: fi}); err != nil {
+					w.fail(err)
+					break
+				}
+			}
+			continue
+		}
+
+		if w.visit(r) {
+			w.wg.Add(1)
+			go w.walkDir(r, 0)
+		}
+	}
+
+	w.wg.Wait()
+	return w.err
+}
+
+// WalkChan is like Walk but delivers entries on a channel. The error
+// channel yields the result of the walk once the entry channel is closed;
+// callers must drain the entry channel.
+func WalkChan(roots []string, opt *WalkOpt) (<-chan WalkEntry, <-chan error) {
+	ch := make(chan WalkEntry, 64)
+	errch := make(chan error, 1)
+
+	go func() {
+		errch <- Walk(roots, opt, func(e WalkEntry) error {
+			ch <- e
+			return nil
+		})
+		close(ch)
+		close(errch)
+	}()
+	return ch, errch
+}
+
+type walker struct {
+	WalkOpt
+
+	fp  func(WalkEntry) error
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	sync.Mutex
+	seen map[string]bool
+	err  error
+}
+
+func (w *walker) walkDir(dir string, depth int) {
+	defer w.wg.Done()
+
+	w.sem <- struct{}{}
+	defer func() {
+		<-w.sem
+	}()
+
+	if w.failed() {
+		return
+	}
+
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+
+	depth++
+	for _, de := range ents {
+		if w.failed() {
+			return
+		}
+
+		nm := de.Name()
+		if w.excluded(nm) {
+			continue
+		}
+
+		path := filepath.Join(dir, nm)
+		fi, err := w.stat(path)
+		if err != nil {
+			w.fail(err)
+			return
+		}
+
+		if fi.IsDir() {
+			if (w.MaxDepth == 0 || depth < w.MaxDepth) && w.visit(path) {
+				w.wg.Add(1)
+				go w.walkDir(path, depth)
+			}
+			continue
+		}
+
+		if !w.included(nm) {
+			continue
+		}
+		if err := w.fp(WalkEntry{Path: path, Info: fi, Depth: depth}); err != nil {
+			w.fail(err)
+			return
+		}
+	}
+}
+
+// stat returns the info of path honoring the symlink policy.
+func (w *walker) stat(path string) (os.FileInfo, error) {
+	if w.FollowSymlinks {
+		return os.Stat(path)
+	}
+	return os.Lstat(path)
+}
+
+// visit returns true the first time a directory is seen. It guards
+// against symlink loops when following symlinks.
+func (w *walker) visit(dir string) bool {
+	if !w.FollowSymlinks {
+		return true
+	}
+
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		w.fail(err)
+		return false
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	if w.seen[real] {
+		return false
+	}
+	w.seen[real] = true
+	return true
+}
+
+func (w *walker) included(nm string) bool {
+	if len(w.Include) == 0 {
+		return !w.excluded(nm)
+	}
+	return match(nm, w.Include) && !w.excluded(nm)
+}
+
+func (w *walker) excluded(nm string) bool {
+	return match(nm, w.Exclude)
+}
+
+func (w *walker) fail(err error) {
+	w.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.Unlock()
+}
+
+func (w *walker) failed() bool {
+	w.Lock()
+	defer w.Unlock()
+	return w.err != nil
+}
+
+// match returns true if nm matches any of the glob patterns.
+func match(nm string, pats []string) bool {
+	for _, p := range pats {
+		if ok, _ := filepath.Match(p, nm); ok {
+			return true
+		}
+	}
+	return false
+}
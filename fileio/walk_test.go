@@ -0,0 +1,114 @@
+package fileio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func mkTree(t *testing.T, files ...string) string {
+	root := t.TempDir()
+	for _, f := range files {
+		fn := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			t.Fatalf("mkdir: %s", err)
+		}
+		if err := os.WriteFile(fn, []byte(f), 0600); err != nil {
+			t.Fatalf("write: %s", err)
+		}
+	}
+	return root
+}
+
+func walkNames(t *testing.T, root string, opt *WalkOpt) string {
+	var mu sync.Mutex
+	var names []string
+
+	err := Walk([]string{root}, opt, func(e WalkEntry) error {
+		rel, _ := filepath.Rel(root, e.Path)
+		mu.Lock()
+		names = append(names, filepath.ToSlash(rel))
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %s", err)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestWalk(t *testing.T) {
+	root := mkTree(t, "a.go", "a.txt", "x/b.go", "x/y/c.go", "x/y/z/d.go", ".git/config", "skip/e.go")
+
+	tests := []struct {
+		opt  WalkOpt
+		want string
+	}{
+		{WalkOpt{}, ".git/config a.go a.txt skip/e.go x/b.go x/y/c.go x/y/z/d.go"},
+		{WalkOpt{Include: []string{"*.go"}, Exclude: []string{".git", "skip"}}, "a.go x/b.go x/y/c.go x/y/z/d.go"},
+		{WalkOpt{Include: []string{"*.go"}, MaxDepth: 2}, "a.go skip/e.go x/b.go"},
+		{WalkOpt{Exclude: []string{"*.txt", "y"}, Concurrency: 1}, ".git/config a.go skip/e.go x/b.go"},
+	}
+
+	for i, tc := range tests {
+		if got := walkNames(t, root, &tc.opt); got != tc.want {
+			t.Errorf("%d: want %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	root := mkTree(t, "d/f")
+	if err := os.Symlink(root, filepath.Join(root, "d", "loop")); err != nil {
+		t.Fatalf("symlink: %s", err)
+	}
+
+	if got := walkNames(t, root, nil); got != "d/f d/loop" {
+		t.Errorf("no follow: got %q", got)
+	}
+	if got := walkNames(t, root, &WalkOpt{FollowSymlinks: true}); got != "d/f" {
+		t.Errorf("follow: got %q", got)
+	}
+}
+
+func TestWalkError(t *testing.T) {
+	root := mkTree(t, "a", "b", "c/d")
+	errStop := errors.New("stop")
+
+	err := Walk([]string{root}, nil, func(e WalkEntry) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("want %v, got %v", errStop, err)
+	}
+
+	if err := Walk([]string{root}, &WalkOpt{Include: []string{"["}}, nil); err == nil {
+		t.Errorf("expected bad pattern error")
+	}
+}
+
+func TestWalkChan(t *testing.T) {
+	root := mkTree(t, "a", "b/c")
+
+	ch, errch := WalkChan([]string{root}, nil)
+	var n int
+	for range ch {
+		n++
+	}
+	if err := <-errch; err != nil {
+		t.Fatalf("walk: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("want 2 entries, got %d", n)
+	}
+}
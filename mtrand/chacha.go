@@ -0,0 +1,188 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mtrand
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"math/rand"
+)
+
+// ChaCha20 is a deterministic random stream made of the ChaCha20
+// keystream (RFC 8439) under a 256-bit seed used as the key. Unlike the
+// other generators in this package its output is unpredictable to anyone
+// who doesn't know the seed, which makes it suitable for reproducible
+// test keys and nonces. It is not safe for concurrent use.
+type ChaCha20 struct {
+	key    [8]uint32
+	ctr    uint64 // block counter
+	stream uint64 // nonce
+
+	buf [64]byte
+	off int
+}
+
+var (
+	_ rand.Source64 = (*ChaCha20)(nil)
+	_ io.Reader     = (*ChaCha20)(nil)
+)
+
+// NewChaCha20 returns a ChaCha20 generator keyed with seed. Generators
+// with different stream numbers produce independent output for the same
+// seed.
+func NewChaCha20(seed [32]byte, stream uint64) *ChaCha20 {
+	c := &ChaCha20{
+		stream: stream,
+	}
+	c.setKey(seed[:])
+	return c
+}
+
+// Seed re-keys the generator from a 64-bit seed (zero extended to 256
+// bits) and restarts the current stream; it implements rand.Source.
+// Prefer NewChaCha20 with a full 256-bit seed for unpredictable output.
+func (c *ChaCha20) Seed(seed int64) {
+	var k [32]byte
+	binary.LittleEndian.PutUint64(k[:], uint64(seed))
+	c.setKey(k[:])
+}
+
+func (c *ChaCha20) setKey(k []byte) {
+	for i := range c.key {
+		c.key[i] = binary.LittleEndian.Uint32(k[i*4:])
+	}
+	c.ctr = 0
+	c.off = len(c.buf)
+}
+
+// Read fills b with the next len(b) bytes of the keystream and always
+// returns len(b), nil. The stream is the same regardless of how it is
+// split across calls.
+func (c *ChaCha20) Read(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		if c.off == len(c.buf) {
+			c.refill()
+		}
+		m := copy(b, c.buf[c.off:])
+		c.off += m
+		b = b[m:]
+	}
+	return n, nil
+}
+
+// Fill fills b with the next len(b) bytes of the keystream.
+func (c *ChaCha20) Fill(b []byte) {
+	c.Read(b)
+}
+
+// Uint64 returns the next 8 bytes of the keystream as a little-endian
+// value.
+func (c *ChaCha20) Uint64() uint64 {
+	if len(c.buf)-c.off < 8 {
+		var b [8]byte
+		c.Read(b[:])
+		return binary.LittleEndian.Uint64(b[:])
+	}
+
+	v := binary.LittleEndian.Uint64(c.buf[c.off:])
+	c.off += 8
+	return v
+}
+
+// Int63 returns a non-negative 63-bit value; it implements rand.Source.
+func (c *ChaCha20) Int63() int64 {
+	return int64(c.Uint64() >> 1)
+}
+
+// Float64 returns a uniformly distributed float64 in [0, 1) with the full
+// 53 bits of precision.
+func (c *ChaCha20) Float64() float64 {
+	return float64n(c)
+}
+
+// Intn returns a uniformly distributed value in [0, n). It panics if
+// n <= 0.
+func (c *ChaCha20) Intn(n int) int {
+	return intn(c, n)
+}
+
+// Int63n returns a uniformly distributed value in [0, n). It panics if
+// n <= 0.
+func (c *ChaCha20) Int63n(n int64) int64 {
+	return int63n(c, n)
+}
+
+// Uint32n returns a uniformly distributed value in [0, n) without modulo
+// bias. It panics if n == 0.
+func (c *ChaCha20) Uint32n(n uint32) uint32 {
+	return uint32n(c, n)
+}
+
+// Perm returns a random permutation of the integers [0, n).
+func (c *ChaCha20) Perm(n int) []int {
+	return perm(c, n)
+}
+
+// Shuffle randomizes the order of n elements using the Fisher-Yates
+// algorithm; swap exchanges the elements at i and j.
+func (c *ChaCha20) Shuffle(n int, swap func(i, j int)) {
+	shuffle(c, n, swap)
+}
+
+// refill generates the next keystream block. The state uses the original
+// layout with a 64-bit block counter and a 64-bit nonce (the stream).
+func (c *ChaCha20) refill() {
+	s := [16]uint32{
+		0x61707865, 0x3320646e, 0x79622d32, 0x6b206574,
+		c.key[0], c.key[1], c.key[2], c.key[3],
+		c.key[4], c.key[5], c.key[6], c.key[7],
+		uint32(c.ctr), uint32(c.ctr >> 32),
+		uint32(c.stream), uint32(c.stream >> 32),
+	}
+
+	x := s
+	for i := 0; i < 10; i++ {
+		// column rounds
+		quarterRound(&x, 0, 4, 8, 12)
+		quarterRound(&x, 1, 5, 9, 13)
+		quarterRound(&x, 2, 6, 10, 14)
+		quarterRound(&x, 3, 7, 11, 15)
+
+		// diagonal rounds
+		quarterRound(&x, 0, 5, 10, 15)
+		quarterRound(&x, 1, 6, 11, 12)
+		quarterRound(&x, 2, 7, 8, 13)
+		quarterRound(&x, 3, 4, 9, 14)
+	}
+
+	for i := range x {
+		binary.LittleEndian.PutUint32(c.buf[i*4:], x[i]+s[i])
+	}
+
+	c.ctr++
+	c.off = 0
+}
+
+func quarterRound(x *[16]uint32, a, b, c, d int) {
+	x[a] += x[b]
+	x[d] = bits.RotateLeft32(x[d]^x[a], 16)
+	x[c] += x[d]
+	x[b] = bits.RotateLeft32(x[b]^x[c], 12)
+	x[a] += x[b]
+	x[d] = bits.RotateLeft32(x[d]^x[a], 8)
+	x[c] += x[d]
+	x[b] = bits.RotateLeft32(x[b]^x[c], 7)
+}
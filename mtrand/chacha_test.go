@@ -0,0 +1,61 @@
+package mtrand
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func TestChaCha20Block(t *testing.T) {
+	// RFC 8439, section 2.3.2. The 96-bit IETF nonce and 32-bit counter
+	// map onto our 64-bit counter and 64-bit stream.
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	c := NewChaCha20(key, 0x4a000000)
+	c.ctr = 1 | 0x09000000<<32
+
+	want, _ := hex.DecodeString("10f1e7e4d13b5915500fdd1fa32071c4c7d1f4c733c068030422aa9ac3d46c4e" +
+		"d2826446079faa0914c2d705d98b02a2b5129cd1de164eb9cbd083e8a2503c4e")
+
+	got := make([]byte, 64)
+	c.Read(got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("block mismatch:\nwant %x\ngot  %x", want, got)
+	}
+}
+
+func TestChaCha20Stream(t *testing.T) {
+	var seed [32]byte
+	copy(seed[:], "deterministic test seed")
+
+	a := make([]byte, 1000)
+	NewChaCha20(seed, 0).Read(a)
+
+	// the stream doesn't depend on read sizes
+	c := NewChaCha20(seed, 0)
+	b := make([]byte, 0, 1000)
+	for _, n := range []int{1, 7, 64, 3, 200, 725} {
+		p := make([]byte, n)
+		c.Read(p)
+		b = append(b, p...)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("chunked reads differ from a single read")
+	}
+
+	other := make([]byte, 1000)
+	NewChaCha20(seed, 1).Read(other)
+	if bytes.Equal(a[:32], other[:32]) {
+		t.Errorf("streams 0 and 1 are identical")
+	}
+
+	c = NewChaCha20(seed, 0)
+	c.Uint64()
+	if v, w := c.Uint64(), binary.LittleEndian.Uint64(a[8:]); v != w {
+		t.Errorf("Uint64 doesn't follow the keystream: want %#x, got %#x", w, v)
+	}
+}
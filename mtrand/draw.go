@@ -0,0 +1,119 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mtrand
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// source64 is the raw output of a generator; the draw helpers below turn
+// it into the distributions exposed by each generator.
+type source64 interface {
+	Uint64() uint64
+}
+
+// uint64n returns a uniformly distributed value in [0, n) without modulo
+// bias, using Lemire's multiply-and-reject method.
+func uint64n(s source64, n uint64) uint64 {
+	hi, lo := bits.Mul64(s.Uint64(), n)
+	if lo < n {
+		thresh := -n % n
+		for lo < thresh {
+			hi, lo = bits.Mul64(s.Uint64(), n)
+		}
+	}
+	return hi
+}
+
+func intn(s source64, n int) int {
+	if n <= 0 {
+		panic("mtrand: invalid argument to Intn")
+	}
+	return int(uint64n(s, uint64(n)))
+}
+
+func int63n(s source64, n int64) int64 {
+	if n <= 0 {
+		panic("mtrand: invalid argument to Int63n")
+	}
+	return int64(uint64n(s, uint64(n)))
+}
+
+func uint32n(s source64, n uint32) uint32 {
+	if n == 0 {
+		panic("mtrand: invalid argument to Uint32n")
+	}
+	return uint32(uint64n(s, uint64(n)))
+}
+
+func float64n(s source64) float64 {
+	return float64(s.Uint64()>>11) * (1.0 / (1 << 53))
+}
+
+func perm(s source64, n int) []int {
+	if n < 0 {
+		panic("mtrand: invalid argument to Perm")
+	}
+
+	p := make([]int, n)
+	for i := range p {
+		j := int(uint64n(s, uint64(i+1)))
+		p[i] = p[j]
+		p[j] = i
+	}
+	return p
+}
+
+func shuffle(s source64, n int, swap func(i, j int)) {
+	if n < 0 {
+		panic("mtrand: invalid argument to Shuffle")
+	}
+
+	for i := n - 1; i > 0; i-- {
+		j := int(uint64n(s, uint64(i+1)))
+		swap(i, j)
+	}
+}
+
+// byteBuf turns a generator's values into a byte stream: eight bytes
+// per value in little-endian order. The bytes of the last value that a
+// call doesn't use are kept for the next one, so the stream doesn't
+// depend on how it is split across calls.
+type byteBuf struct {
+	v uint64 // unused bytes, next one lowest
+	n int    // number of unused bytes in v
+}
+
+func (r *byteBuf) fill(s source64, b []byte) {
+	for ; r.n > 0 && len(b) > 0; r.n-- {
+		b[0] = byte(r.v)
+		r.v >>= 8
+		b = b[1:]
+	}
+
+	for len(b) >= 8 {
+		binary.LittleEndian.PutUint64(b, s.Uint64())
+		b = b[8:]
+	}
+
+	if len(b) > 0 {
+		v := s.Uint64()
+		for i := range b {
+			b[i] = byte(v)
+			v >>= 8
+		}
+		r.v, r.n = v, 8-len(b)
+	}
+}
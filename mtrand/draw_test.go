@@ -0,0 +1,182 @@
+package mtrand
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+	"testing/iotest"
+)
+
+// every generator plugs into math/rand and is reproducible through it
+func TestSources(t *testing.T) {
+	for name, mk := range map[string]func() rand.Source64{
+		"MT64":     func() rand.Source64 { return NewMT64(1) },
+		"PCG64":    func() rand.Source64 { return NewPCG64(1, 0) },
+		"ChaCha20": func() rand.Source64 { return NewChaCha20([32]byte{1}, 0) },
+	} {
+		r1, r2 := rand.New(mk()), rand.New(mk())
+		r1.Seed(42)
+		r2.Seed(42)
+		if p, q := r1.Perm(20), r2.Perm(20); !slices.Equal(p, q) {
+			t.Errorf("%s: same seed, different Perm: %v %v", name, p, q)
+		}
+		for i := 0; i < 1000; i++ {
+			if f := r1.Float64(); f < 0 || f >= 1 {
+				t.Fatalf("%s: Float64 out of range: %v", name, f)
+			}
+			if v := r1.Int63(); v < 0 {
+				t.Fatalf("%s: negative Int63: %d", name, v)
+			}
+		}
+
+		r1.Seed(7)
+		if a, b := r1.Uint64(), r2.Uint64(); a == b {
+			t.Errorf("%s: reseeding had no effect", name)
+		}
+	}
+}
+
+func TestDraws(t *testing.T) {
+	m := NewMT64(2024)
+
+	var hist [7]int
+	for i := 0; i < 70000; i++ {
+		v := m.Intn(7)
+		if v < 0 || v >= 7 {
+			t.Fatalf("Intn out of range: %d", v)
+		}
+		hist[v]++
+	}
+	for i, n := range hist {
+		if n < 9000 || n > 11000 {
+			t.Errorf("Intn(7): bucket %d has %d samples", i, n)
+		}
+	}
+
+	for i := 0; i < 10000; i++ {
+		if v := m.Int63n(1 << 40); v < 0 || v >= 1<<40 {
+			t.Fatalf("Int63n out of range: %d", v)
+		}
+		if v := m.Uint32n(3); v >= 3 {
+			t.Fatalf("Uint32n out of range: %d", v)
+		}
+	}
+
+	p := m.Perm(100)
+	q := append([]int(nil), p...)
+	sort.Ints(q)
+	for i := range q {
+		if q[i] != i {
+			t.Fatalf("Perm is not a permutation: %v", p)
+		}
+	}
+
+	v := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	m.Shuffle(len(v), func(i, j int) { v[i], v[j] = v[j], v[i] })
+	sort.Ints(v)
+	for i := range v {
+		if v[i] != i {
+			t.Fatalf("Shuffle lost elements: %v", v)
+		}
+	}
+}
+
+func TestDrawPanics(t *testing.T) {
+	m := NewMT64(1)
+	tests := map[string]func(){
+		"Intn":    func() { m.Intn(0) },
+		"Int63n":  func() { m.Int63n(-1) },
+		"Uint32n": func() { m.Uint32n(0) },
+		"Perm":    func() { m.Perm(-1) },
+	}
+
+	for name, fp := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			fp()
+		}()
+	}
+}
+
+func TestReadSplit(t *testing.T) {
+	srcs := map[string]func() io.Reader{
+		"MT64":     func() io.Reader { return NewMT64(5) },
+		"PCG64":    func() io.Reader { return NewPCG64(5, 1) },
+		"ChaCha20": func() io.Reader { return NewChaCha20([32]byte{5}, 0) },
+	}
+
+	for name, mk := range srcs {
+		want := make([]byte, 64)
+		mk().Read(want)
+
+		for _, sizes := range [][]int{{8, 8}, {3, 13}, {1, 1, 1, 5, 7, 9, 2}, {64}} {
+			r := mk()
+			var got []byte
+			for _, n := range sizes {
+				b := make([]byte, n)
+				r.Read(b)
+				got = append(got, b...)
+			}
+			if !bytes.Equal(got, want[:len(got)]) {
+				t.Errorf("%s: reads of %v differ from one read:\n%x\n%x", name, sizes, got, want[:len(got)])
+			}
+		}
+
+		// nor does a reader that passes on one byte at a time
+		got := make([]byte, 37)
+		if _, err := io.ReadFull(iotest.OneByteReader(mk()), got); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !bytes.Equal(got, want[:37]) {
+			t.Errorf("%s: byte-at-a-time stream differs", name)
+		}
+	}
+
+	// reseeding drops the bytes left over from a Read
+	m := NewMT64(5)
+	m.Read(make([]byte, 3))
+	m.Seed(5)
+	got := make([]byte, 16)
+	m.Read(got)
+	want := make([]byte, 16)
+	NewMT64(5).Read(want)
+	if !bytes.Equal(got, want) {
+		t.Errorf("leftover bytes survived Seed:\n%x\n%x", got, want)
+	}
+}
+
+func TestFill(t *testing.T) {
+	a := make([]byte, 37)
+	b := make([]byte, 37)
+
+	NewMT64(5).Fill(a)
+	if _, err := io.ReadFull(NewMT64(5), b); err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("Fill and Read differ:\n%x\n%x", a, b)
+	}
+
+	m := NewMT64(5)
+	want := m.Uint64()
+	if got := binary.LittleEndian.Uint64(a); got != want {
+		t.Errorf("first word: want %#x, got %#x", want, got)
+	}
+}
+
+func BenchmarkFill(b *testing.B) {
+	m := NewMT64(1)
+	buf := make([]byte, 4096)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		m.Fill(buf)
+	}
+}
@@ -0,0 +1,358 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package mtrand provides seedable, deterministic pseudo random number
+// generators such as the Mersenne Twister.
+package mtrand
+
+import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+)
+
+// ErrInvalidState is returned when unmarshaling a malformed generator
+// state.
+var ErrInvalidState = errors.New("mtrand: invalid generator state")
+
+const (
+	nn64      = 312
+	mm64      = 156
+	matrixA64 = 0xB5026F5AA96619E9
+	upper64   = 0xFFFFFFFF80000000 // most significant 33 bits
+	lower64   = 0x7FFFFFFF         // least significant 31 bits
+)
+
+// MT64 is the 64-bit Mersenne Twister MT19937-64 of Matsumoto and
+// Nishimura. It is not safe for concurrent use.
+type MT64 struct {
+	mt  [nn64]uint64
+	mti int
+	rb  byteBuf
+}
+
+// MT64 can be used as the source for a math/rand.Rand and as a stream of
+// random bytes.
+var (
+	_ rand.Source64 = (*MT64)(nil)
+	_ io.Reader     = (*MT64)(nil)
+)
+
+// NewMT64 returns a MT64 generator seeded with seed.
+func NewMT64(seed uint64) *MT64 {
+	m := &MT64{}
+	m.Seed64(seed)
+	return m
+}
+
+// Seed64 re-initializes the generator state from seed (init_genrand64 in
+// the reference implementation).
+func (m *MT64) Seed64(seed uint64) {
+	m.mt[0] = seed
+	for i := 1; i < nn64; i++ {
+		x := m.mt[i-1]
+		m.mt[i] = 6364136223846793005*(x^(x>>62)) + uint64(i)
+	}
+	m.mti = nn64
+	m.rb = byteBuf{}
+}
+
+// NewMT64Array returns a MT64 generator seeded with an arbitrary length
+// key; this is the reference way to use seeds wider than 64 bits.
+func NewMT64Array(key []uint64) *MT64 {
+	m := &MT64{}
+	m.SeedArray(key)
+	return m
+}
+
+// NewMT64FromCrypto returns a MT64 generator whose entire state is drawn
+// from crypto/rand rather than expanded from a single 64-bit seed.
+func NewMT64FromCrypto() (*MT64, error) {
+	var b [nn64 * 8]byte
+	if _, err := io.ReadFull(crand.Reader, b[:]); err != nil {
+		return nil, err
+	}
+
+	m := &MT64{}
+	for i := range m.mt {
+		m.mt[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+
+	// as in init_by_array64: guarantee a non-zero initial state
+	m.mt[0] = 1 << 63
+	m.mti = nn64
+	return m, nil
+}
+
+// SeedArray re-initializes the generator state from key (init_by_array64
+// in the reference implementation). An empty key selects the reference
+// default seed, 5489.
+func (m *MT64) SeedArray(key []uint64) {
+	if len(key) == 0 {
+		m.Seed64(5489)
+		return
+	}
+
+	m.Seed64(19650218)
+	mt := &m.mt
+
+	i, j := 1, 0
+	k := nn64
+	if len(key) > k {
+		k = len(key)
+	}
+	for ; k > 0; k-- {
+		x := mt[i-1]
+		mt[i] = (mt[i] ^ ((x ^ (x >> 62)) * 3935559000370003845)) + key[j] + uint64(j)
+		i++
+		j++
+		if i >= nn64 {
+			mt[0] = mt[nn64-1]
+			i = 1
+		}
+		if j >= len(key) {
+			j = 0
+		}
+	}
+
+	for k = nn64 - 1; k > 0; k-- {
+		x := mt[i-1]
+		mt[i] = (mt[i] ^ ((x ^ (x >> 62)) * 2862933555777941757)) - uint64(i)
+		i++
+		if i >= nn64 {
+			mt[0] = mt[nn64-1]
+			i = 1
+		}
+	}
+
+	mt[0] = 1 << 63
+	m.mti = nn64
+	m.rb = byteBuf{}
+}
+
+// Seed re-initializes the generator state from seed; it implements
+// rand.Source.
+func (m *MT64) Seed(seed int64) {
+	m.Seed64(uint64(seed))
+}
+
+// Int63 returns a non-negative 63-bit value; it implements rand.Source.
+func (m *MT64) Int63() int64 {
+	return int64(m.Uint64() >> 1)
+}
+
+// Uint64 returns a uniformly distributed 64-bit value.
+func (m *MT64) Uint64() uint64 {
+	if m.mti >= nn64 {
+		m.twist()
+	}
+
+	x := m.mt[m.mti]
+	m.mti++
+	return temper64(x)
+}
+
+// FillUint64 fills v with the same values that len(v) calls to Uint64
+// would return, tempering whole runs of the state at a time.
+func (m *MT64) FillUint64(v []uint64) {
+	for len(v) > 0 {
+		if m.mti >= nn64 {
+			m.twist()
+		}
+
+		src := m.mt[m.mti:]
+		if len(src) > len(v) {
+			src = src[:len(v)]
+		}
+
+		dst := v[:len(src)]
+		for i, x := range src {
+			dst[i] = temper64(x)
+		}
+
+		m.mti += len(src)
+		v = v[len(src):]
+	}
+}
+
+// FillUint32 fills v with 32-bit values; each 64-bit output provides two
+// of them, low half first. If len(v) is odd the high half of the last
+// 64-bit value is discarded.
+func (m *MT64) FillUint32(v []uint32) {
+	var buf [nn64]uint64
+
+	for len(v) > 0 {
+		n := (len(v) + 1) / 2
+		if n > len(buf) {
+			n = len(buf)
+		}
+
+		w := buf[:n]
+		m.FillUint64(w)
+		for i, x := range w {
+			v[2*i] = uint32(x)
+			if 2*i+1 < len(v) {
+				v[2*i+1] = uint32(x >> 32)
+			}
+		}
+
+		if 2*n >= len(v) {
+			break
+		}
+		v = v[2*n:]
+	}
+}
+
+func temper64(x uint64) uint64 {
+	x ^= (x >> 29) & 0x5555555555555555
+	x ^= (x << 17) & 0x71D67FFFEDA60000
+	x ^= (x << 37) & 0xFFF7EEE000000000
+	x ^= x >> 43
+	return x
+}
+
+// Float64 returns a uniformly distributed float64 in [0, 1) with the full
+// 53 bits of precision.
+func (m *MT64) Float64() float64 {
+	return float64n(m)
+}
+
+// Intn returns a uniformly distributed value in [0, n). It panics if
+// n <= 0.
+func (m *MT64) Intn(n int) int {
+	return intn(m, n)
+}
+
+// Int63n returns a uniformly distributed value in [0, n). It panics if
+// n <= 0.
+func (m *MT64) Int63n(n int64) int64 {
+	return int63n(m, n)
+}
+
+// Uint32n returns a uniformly distributed value in [0, n) without modulo
+// bias. It panics if n == 0.
+func (m *MT64) Uint32n(n uint32) uint32 {
+	return uint32n(m, n)
+}
+
+// Perm returns a random permutation of the integers [0, n).
+func (m *MT64) Perm(n int) []int {
+	return perm(m, n)
+}
+
+// Shuffle randomizes the order of n elements using the Fisher-Yates
+// algorithm; swap exchanges the elements at i and j.
+func (m *MT64) Shuffle(n int, swap func(i, j int)) {
+	shuffle(m, n, swap)
+}
+
+// Fill fills b with random bytes, eight per 64-bit value in
+// little-endian order. Bytes left over from the last value are used by
+// the next Fill or Read, so the stream for a seed is the same however it
+// is split across calls.
+func (m *MT64) Fill(b []byte) {
+	m.rb.fill(m, b)
+}
+
+// Read fills b with random bytes and always returns len(b), nil; it
+// implements io.Reader with the same semantics as Fill.
+func (m *MT64) Read(b []byte) (int, error) {
+	m.rb.fill(m, b)
+	return len(b), nil
+}
+
+// mt64Magic identifies a marshaled MT64 state.
+const mt64Magic = "MT64"
+
+// MarshalBinary returns the complete generator state such that a
+// generator restored with UnmarshalBinary produces exactly the same
+// sequence of values; it implements encoding.BinaryMarshaler. Bytes left
+// over from a Read are not included, so a restored generator's next
+// Read starts at the next value.
+func (m *MT64) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(mt64Magic)+2+nn64*8)
+	b = append(b, mt64Magic...)
+	b = binary.BigEndian.AppendUint16(b, uint16(m.mti))
+	for _, v := range m.mt {
+		b = binary.BigEndian.AppendUint64(b, v)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary restores a state saved by MarshalBinary; it implements
+// encoding.BinaryUnmarshaler.
+func (m *MT64) UnmarshalBinary(b []byte) error {
+	if len(b) != len(mt64Magic)+2+nn64*8 || string(b[:len(mt64Magic)]) != mt64Magic {
+		return ErrInvalidState
+	}
+	b = b[len(mt64Magic):]
+
+	mti := int(binary.BigEndian.Uint16(b))
+	if mti > nn64 {
+		return ErrInvalidState
+	}
+	b = b[2:]
+
+	for i := range m.mt {
+		m.mt[i] = binary.BigEndian.Uint64(b[i*8:])
+	}
+	m.mti = mti
+	m.rb = byteBuf{}
+	return nil
+}
+
+// MarshalText returns the generator state as unpadded base64; it
+// implements encoding.TextMarshaler.
+func (m *MT64) MarshalText() ([]byte, error) {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := make([]byte, base64.RawStdEncoding.EncodedLen(len(b)))
+	base64.RawStdEncoding.Encode(t, b)
+	return t, nil
+}
+
+// UnmarshalText restores a state saved by MarshalText; it implements
+// encoding.TextUnmarshaler.
+func (m *MT64) UnmarshalText(t []byte) error {
+	b := make([]byte, base64.RawStdEncoding.DecodedLen(len(t)))
+	n, err := base64.RawStdEncoding.Decode(b, t)
+	if err != nil {
+		return ErrInvalidState
+	}
+	return m.UnmarshalBinary(b[:n])
+}
+
+// twist generates the next nn64 words of state.
+func (m *MT64) twist() {
+	mt := &m.mt
+
+	var i int
+	for ; i < nn64-mm64; i++ {
+		x := (mt[i] & upper64) | (mt[i+1] & lower64)
+		mt[i] = mt[i+mm64] ^ (x >> 1) ^ ((x & 1) * matrixA64)
+	}
+	for ; i < nn64-1; i++ {
+		x := (mt[i] & upper64) | (mt[i+1] & lower64)
+		mt[i] = mt[i+(mm64-nn64)] ^ (x >> 1) ^ ((x & 1) * matrixA64)
+	}
+	x := (mt[nn64-1] & upper64) | (mt[0] & lower64)
+	mt[nn64-1] = mt[mm64-1] ^ (x >> 1) ^ ((x & 1) * matrixA64)
+
+	m.mti = 0
+}
@@ -0,0 +1,192 @@
+package mtrand
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMT64(t *testing.T) {
+	m := NewMT64(5489)
+
+	if got := m.Uint64(); got != 14514284786278117030 {
+		t.Errorf("first value: want 14514284786278117030, got %d", got)
+	}
+
+	// The C++ standard requires the 10000th value of a default
+	// constructed mt19937_64 to be 9981545732273789042.
+	var got uint64
+	for i := 1; i < 10000; i++ {
+		got = m.Uint64()
+	}
+	if got != 9981545732273789042 {
+		t.Errorf("10000th value: want 9981545732273789042, got %d", got)
+	}
+}
+
+func TestMT64Float64(t *testing.T) {
+	m := NewMT64(1)
+	for i := 0; i < 100000; i++ {
+		f := m.Float64()
+		if f < 0 || f >= 1 {
+			t.Fatalf("Float64 out of range: %v", f)
+		}
+	}
+}
+
+func BenchmarkMT64(b *testing.B) {
+	m := NewMT64(5489)
+	b.SetBytes(8)
+	for i := 0; i < b.N; i++ {
+		m.Uint64()
+	}
+}
+
+func TestMT64Source(t *testing.T) {
+	r1 := rand.New(NewMT64(42))
+	r2 := rand.New(NewMT64(0))
+	r2.Seed(42)
+
+	for i := 0; i < 1000; i++ {
+		a, b := r1.Int63(), r2.Int63()
+		if a != b {
+			t.Fatalf("%d: sequences differ: %d != %d", i, a, b)
+		}
+		if a < 0 {
+			t.Fatalf("%d: negative Int63: %d", i, a)
+		}
+	}
+
+	p1 := rand.New(NewMT64(7)).Perm(50)
+	p2 := rand.New(NewMT64(7)).Perm(50)
+	for i := range p1 {
+		if p1[i] != p2[i] {
+			t.Fatalf("Perm not deterministic at %d", i)
+		}
+	}
+}
+
+func TestMT64Marshal(t *testing.T) {
+	m := NewMT64(99)
+	for i := 0; i < 500; i++ {
+		m.Uint64()
+	}
+
+	b, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+	txt, err := m.MarshalText()
+	if err != nil {
+		t.Fatalf("marshal text: %s", err)
+	}
+
+	var m1, m2 MT64
+	if err := m1.UnmarshalBinary(b); err != nil {
+		t.Fatalf("unmarshal: %s", err)
+	}
+	if err := m2.UnmarshalText(txt); err != nil {
+		t.Fatalf("unmarshal text: %s", err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		want := m.Uint64()
+		if a, b := m1.Uint64(), m2.Uint64(); a != want || b != want {
+			t.Fatalf("%d: want %d, got %d and %d", i, want, a, b)
+		}
+	}
+
+	if err := m1.UnmarshalBinary(b[:10]); err != ErrInvalidState {
+		t.Errorf("short state: want ErrInvalidState, got %v", err)
+	}
+	if err := m1.UnmarshalText([]byte("!!!")); err != ErrInvalidState {
+		t.Errorf("bad text: want ErrInvalidState, got %v", err)
+	}
+}
+
+func TestMT64Array(t *testing.T) {
+	// first values of mt19937-64.out from the reference implementation
+	want := []uint64{
+		7266447313870364031,
+		4946485549665804864,
+		16945909448695747420,
+		16394063075524226720,
+		4873882236456199058,
+	}
+
+	m := NewMT64Array([]uint64{0x12345, 0x23456, 0x34567, 0x45678})
+	for i, w := range want {
+		if got := m.Uint64(); got != w {
+			t.Errorf("%d: want %d, got %d", i, w, got)
+		}
+	}
+
+	// an empty key is the default seed
+	m = NewMT64Array(nil)
+	d := NewMT64(5489)
+	for i := 0; i < 10; i++ {
+		if x, y := m.Uint64(), d.Uint64(); x != y {
+			t.Fatalf("%d: empty key: want %d, got %d", i, y, x)
+		}
+	}
+}
+
+func TestMT64FromCrypto(t *testing.T) {
+	a, err := NewMT64FromCrypto()
+	if err != nil {
+		t.Fatalf("crypto seed: %s", err)
+	}
+	b, err := NewMT64FromCrypto()
+	if err != nil {
+		t.Fatalf("crypto seed: %s", err)
+	}
+
+	if a.Uint64() == b.Uint64() && a.Uint64() == b.Uint64() {
+		t.Errorf("two crypto seeded generators produce the same output")
+	}
+}
+
+func TestMT64Fill(t *testing.T) {
+	for _, n := range []int{0, 1, 311, 312, 313, 1000} {
+		a := NewMT64(3)
+		b := NewMT64(3)
+		a.Uint64() // start mid-state
+
+		v := make([]uint64, n)
+		b.Uint64()
+		b.FillUint64(v)
+		for i := range v {
+			if w := a.Uint64(); v[i] != w {
+				t.Fatalf("n=%d, %d: want %d, got %d", n, i, w, v[i])
+			}
+		}
+		if a.Uint64() != b.Uint64() {
+			t.Fatalf("n=%d: generators out of step after FillUint64", n)
+		}
+	}
+
+	for _, n := range []int{1, 2, 623, 624, 625, 2001} {
+		a := NewMT64(4)
+		b := NewMT64(4)
+
+		v := make([]uint32, n)
+		b.FillUint32(v)
+		for i := 0; i < n; i += 2 {
+			w := a.Uint64()
+			if v[i] != uint32(w) || (i+1 < n && v[i+1] != uint32(w>>32)) {
+				t.Fatalf("n=%d, %d: mismatch", n, i)
+			}
+		}
+		if a.Uint64() != b.Uint64() {
+			t.Fatalf("n=%d: generators out of step after FillUint32", n)
+		}
+	}
+}
+
+func BenchmarkMT64FillUint64(b *testing.B) {
+	m := NewMT64(5489)
+	v := make([]uint64, 4096)
+	b.SetBytes(int64(len(v) * 8))
+	for i := 0; i < b.N; i++ {
+		m.FillUint64(v)
+	}
+}
@@ -0,0 +1,194 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mtrand
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"math/rand"
+)
+
+// 128-bit LCG multiplier from the PCG reference implementation.
+const (
+	pcgMulHi = 0x2360ED051FC65DA4
+	pcgMulLo = 0x4385DF649FCCF645
+)
+
+// PCG64 is the PCG-XSL-RR 128/64 generator of M. E. O'Neill: a 128-bit
+// LCG with a 64-bit permuted output. It has a period of 2^128 and 2^127
+// selectable streams in 32 bytes of state. It is not safe for concurrent
+// use.
+type PCG64 struct {
+	hi, lo       uint64 // state
+	inchi, inclo uint64 // increment; always odd
+	rb           byteBuf
+}
+
+var (
+	_ rand.Source64 = (*PCG64)(nil)
+	_ io.Reader     = (*PCG64)(nil)
+)
+
+// NewPCG64 returns a PCG64 generator seeded with seed on the given stream;
+// generators on different streams produce unrelated sequences.
+func NewPCG64(seed, stream uint64) *PCG64 {
+	p := &PCG64{
+		inchi: stream >> 63,
+		inclo: stream<<1 | 1,
+	}
+	p.Seed64(seed)
+	return p
+}
+
+// Seed64 re-initializes the generator state from seed, keeping the
+// current stream (pcg64_srandom_r in the reference implementation).
+func (p *PCG64) Seed64(seed uint64) {
+	p.hi, p.lo = 0, 0
+	p.step()
+
+	var c uint64
+	p.lo, c = bits.Add64(p.lo, seed, 0)
+	p.hi += c
+	p.step()
+	p.rb = byteBuf{}
+}
+
+// Seed re-initializes the generator state from seed; it implements
+// rand.Source.
+func (p *PCG64) Seed(seed int64) {
+	p.Seed64(uint64(seed))
+}
+
+// Uint64 returns a uniformly distributed 64-bit value.
+func (p *PCG64) Uint64() uint64 {
+	p.step()
+	return bits.RotateLeft64(p.hi^p.lo, -int(p.hi>>58))
+}
+
+// Int63 returns a non-negative 63-bit value; it implements rand.Source.
+func (p *PCG64) Int63() int64 {
+	return int64(p.Uint64() >> 1)
+}
+
+// Float64 returns a uniformly distributed float64 in [0, 1) with the full
+// 53 bits of precision.
+func (p *PCG64) Float64() float64 {
+	return float64n(p)
+}
+
+// Intn returns a uniformly distributed value in [0, n). It panics if
+// n <= 0.
+func (p *PCG64) Intn(n int) int {
+	return intn(p, n)
+}
+
+// Int63n returns a uniformly distributed value in [0, n). It panics if
+// n <= 0.
+func (p *PCG64) Int63n(n int64) int64 {
+	return int63n(p, n)
+}
+
+// Uint32n returns a uniformly distributed value in [0, n) without modulo
+// bias. It panics if n == 0.
+func (p *PCG64) Uint32n(n uint32) uint32 {
+	return uint32n(p, n)
+}
+
+// Perm returns a random permutation of the integers [0, n).
+func (p *PCG64) Perm(n int) []int {
+	return perm(p, n)
+}
+
+// Shuffle randomizes the order of n elements using the Fisher-Yates
+// algorithm; swap exchanges the elements at i and j.
+func (p *PCG64) Shuffle(n int, swap func(i, j int)) {
+	shuffle(p, n, swap)
+}
+
+// Fill fills b with random bytes; see MT64.Fill.
+func (p *PCG64) Fill(b []byte) {
+	p.rb.fill(p, b)
+}
+
+// Read fills b with random bytes and always returns len(b), nil; it
+// implements io.Reader.
+func (p *PCG64) Read(b []byte) (int, error) {
+	p.rb.fill(p, b)
+	return len(b), nil
+}
+
+// pcg64Magic identifies a marshaled PCG64 state.
+const pcg64Magic = "PCG64"
+
+// MarshalBinary returns the complete generator state, including the
+// stream; it implements encoding.BinaryMarshaler. As with MT64, bytes
+// left over from a Read are not included.
+func (p *PCG64) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(pcg64Magic)+4*8)
+	b = append(b, pcg64Magic...)
+	for _, v := range []uint64{p.hi, p.lo, p.inchi, p.inclo} {
+		b = binary.BigEndian.AppendUint64(b, v)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary restores a state saved by MarshalBinary; it implements
+// encoding.BinaryUnmarshaler.
+func (p *PCG64) UnmarshalBinary(b []byte) error {
+	if len(b) != len(pcg64Magic)+4*8 || string(b[:len(pcg64Magic)]) != pcg64Magic {
+		return ErrInvalidState
+	}
+	b = b[len(pcg64Magic):]
+
+	inclo := binary.BigEndian.Uint64(b[24:])
+	if inclo&1 == 0 {
+		return ErrInvalidState
+	}
+
+	p.hi = binary.BigEndian.Uint64(b)
+	p.lo = binary.BigEndian.Uint64(b[8:])
+	p.inchi = binary.BigEndian.Uint64(b[16:])
+	p.inclo = inclo
+	p.rb = byteBuf{}
+	return nil
+}
+
+// MarshalText returns the generator state as unpadded base64; it
+// implements encoding.TextMarshaler.
+func (p *PCG64) MarshalText() ([]byte, error) {
+	b, _ := p.MarshalBinary()
+	return []byte(base64.RawStdEncoding.EncodeToString(b)), nil
+}
+
+// UnmarshalText restores a state saved by MarshalText; it implements
+// encoding.TextUnmarshaler.
+func (p *PCG64) UnmarshalText(t []byte) error {
+	b, err := base64.RawStdEncoding.DecodeString(string(t))
+	if err != nil {
+		return ErrInvalidState
+	}
+	return p.UnmarshalBinary(b)
+}
+
+// step advances the LCG: state = state * mul + inc (mod 2^128).
+func (p *PCG64) step() {
+	hi, lo := bits.Mul64(p.lo, pcgMulLo)
+	hi += p.hi*pcgMulLo + p.lo*pcgMulHi
+
+	var c uint64
+	p.lo, c = bits.Add64(lo, p.inclo, 0)
+	p.hi = hi + p.inchi + c
+}
@@ -0,0 +1,84 @@
+package mtrand
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPCG64(t *testing.T) {
+	// From the PCG reference implementation's pcg64-demo (seed 42,
+	// stream 54).
+	want := []uint64{
+		0x86b1da1d72062b68,
+		0x1304aa46c9853d39,
+		0xa3670e9e0dd50358,
+		0xf9090e529a7dae00,
+		0xc85b9fd837996f2c,
+		0x606121f8e3919196,
+	}
+
+	p := NewPCG64(42, 54)
+	for i, w := range want {
+		if got := p.Uint64(); got != w {
+			t.Errorf("%d: want %#x, got %#x", i, w, got)
+		}
+	}
+}
+
+func TestPCG64Streams(t *testing.T) {
+	a := NewPCG64(1, 1)
+	b := NewPCG64(1, 2)
+
+	var same int
+	for i := 0; i < 100; i++ {
+		if a.Uint64() == b.Uint64() {
+			same++
+		}
+	}
+	if same > 1 {
+		t.Errorf("streams 1 and 2 produced %d identical values", same)
+	}
+
+	r := rand.New(NewPCG64(0, 3))
+	r.Seed(9)
+	c := NewPCG64(9, 3)
+	for i := 0; i < 100; i++ {
+		if x, y := r.Uint64(), c.Uint64(); x != y {
+			t.Fatalf("%d: reseeded generator differs: %#x != %#x", i, x, y)
+		}
+	}
+}
+
+func TestPCG64Marshal(t *testing.T) {
+	p := NewPCG64(77, 5)
+	p.Uint64()
+
+	txt, err := p.MarshalText()
+	if err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+
+	var q PCG64
+	if err := q.UnmarshalText(txt); err != nil {
+		t.Fatalf("unmarshal: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		if x, y := p.Uint64(), q.Uint64(); x != y {
+			t.Fatalf("%d: restored generator differs", i)
+		}
+	}
+
+	b, _ := p.MarshalBinary()
+	b[len(b)-1] &^= 1
+	if err := q.UnmarshalBinary(b); err != ErrInvalidState {
+		t.Errorf("even increment: want ErrInvalidState, got %v", err)
+	}
+}
+
+func BenchmarkPCG64(b *testing.B) {
+	p := NewPCG64(1, 1)
+	b.SetBytes(8)
+	for i := 0; i < b.N; i++ {
+		p.Uint64()
+	}
+}
@@ -0,0 +1,259 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned when a circuit breaker rejects a call.
+var ErrBreakerOpen = errors.New("breaker: circuit open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all calls through.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects all calls.
+	BreakerOpen
+
+	// BreakerHalfOpen lets a few trial calls through to probe whether
+	// the downstream service has recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerOpt configures a circuit breaker. Zero values select the
+// defaults noted below.
+type BreakerOpt struct {
+	// Window is the interval over which the failure rate is measured;
+	// the counts are reset at the start of every window. Default 10s.
+	Window time.Duration
+
+	// MinCalls is the number of calls needed in a window before the
+	// breaker can trip. Default 10.
+	MinCalls int
+
+	// FailureRate is the fraction of failed calls in a window that
+	// trips the breaker. Default 0.5.
+	FailureRate float64
+
+	// SlowCall, if non-zero, counts successful calls that take longer
+	// than this as failures.
+	SlowCall time.Duration
+
+	// OpenFor is how long the breaker stays open before allowing trial
+	// calls. Default 30s.
+	OpenFor time.Duration
+
+	// Trials is the number of successful trial calls in the half-open
+	// state needed to close the breaker. Default 1.
+	Trials int
+
+	// OnStateChange, if set, is called (without locks held) on every
+	// state transition.
+	OnStateChange func(from, to BreakerState)
+}
+
+// Breaker is a circuit breaker that stops calling a failing downstream
+// service for a while, giving it time to recover. It is safe for
+// concurrent use.
+type Breaker struct {
+	opt BreakerOpt
+
+	mu       sync.Mutex
+	state    BreakerState
+	start    time.Time // start of the current window or of the open state
+	calls    int
+	failures int
+	inflight int // trial calls in the half-open state
+	ok       int // successful trial calls
+
+	// gen changes on every transition and window reset so that
+	// outcomes of calls started earlier are ignored
+	gen uint64
+
+	now func() time.Time
+}
+
+// NewBreaker returns a closed circuit breaker.
+func NewBreaker(opt *BreakerOpt) *Breaker {
+	var o BreakerOpt
+	if opt != nil {
+		o = *opt
+	}
+	if o.Window <= 0 {
+		o.Window = 10 * time.Second
+	}
+	if o.MinCalls <= 0 {
+		o.MinCalls = 10
+	}
+	if o.FailureRate <= 0 || o.FailureRate > 1 {
+		o.FailureRate = 0.5
+	}
+	if o.OpenFor <= 0 {
+		o.OpenFor = 30 * time.Second
+	}
+	if o.Trials <= 0 {
+		o.Trials = 1
+	}
+
+	b := &Breaker{
+		opt: o,
+		now: time.Now,
+	}
+	b.start = b.now()
+	return b
+}
+
+// Do calls fn if the breaker allows it and records the outcome. It
+// returns ErrBreakerOpen without calling fn if the circuit is open. A
+// panic in fn is recorded as a failure and then propagated.
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+
+	finished := false
+	defer func() {
+		if !finished {
+			done(errBreakerPanic)
+		}
+	}()
+
+	err = fn()
+	finished = true
+	done(err)
+	return err
+}
+
+// errBreakerPanic is the outcome recorded for a call that panicked.
+var errBreakerPanic = errors.New("breaker: call panicked")
+
+// Allow asks the breaker for permission to make a call. On success, the
+// caller must invoke done with the outcome of the call.
+func (b *Breaker) Allow() (done func(error), err error) {
+	b.mu.Lock()
+	now := b.now()
+	from := b.state
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.start) < b.opt.OpenFor {
+			b.mu.Unlock()
+			return nil, ErrBreakerOpen
+		}
+		b.setState(BreakerHalfOpen, now)
+		fallthrough
+
+	case BreakerHalfOpen:
+		if b.inflight+b.ok >= b.opt.Trials {
+			to := b.state
+			b.mu.Unlock()
+			b.notify(from, to)
+			return nil, ErrBreakerOpen
+		}
+		b.inflight++
+
+	case BreakerClosed:
+		if now.Sub(b.start) >= b.opt.Window {
+			// calls from the old window must not count in the new one
+			b.gen++
+			b.start = now
+			b.calls, b.failures = 0, 0
+		}
+	}
+
+	to, gen := b.state, b.gen
+	b.mu.Unlock()
+	b.notify(from, to)
+
+	t0 := now
+	return func(err error) {
+		b.record(err, t0, gen)
+	}, nil
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) record(err error, t0 time.Time, gen uint64) {
+	b.mu.Lock()
+	if gen != b.gen {
+		b.mu.Unlock()
+		return
+	}
+
+	now := b.now()
+	from := b.state
+
+	failed := err != nil || (b.opt.SlowCall > 0 && now.Sub(t0) > b.opt.SlowCall)
+	switch b.state {
+	case BreakerHalfOpen:
+		b.inflight--
+		if failed {
+			b.setState(BreakerOpen, now)
+		} else if b.ok++; b.ok >= b.opt.Trials {
+			b.setState(BreakerClosed, now)
+		}
+
+	case BreakerClosed:
+		b.calls++
+		if failed {
+			b.failures++
+		}
+		if b.calls >= b.opt.MinCalls && float64(b.failures) >= b.opt.FailureRate*float64(b.calls) {
+			b.setState(BreakerOpen, now)
+		}
+	}
+
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// setState must be called with the lock held.
+func (b *Breaker) setState(s BreakerState, now time.Time) {
+	b.state = s
+	b.gen++
+	b.start = now
+	b.calls, b.failures = 0, 0
+	b.inflight, b.ok = 0, 0
+}
+
+func (b *Breaker) notify(from, to BreakerState) {
+	if from != to && b.opt.OnStateChange != nil {
+		b.opt.OnStateChange(from, to)
+	}
+}
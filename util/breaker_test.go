@@ -0,0 +1,164 @@
+package util
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a breaker driven by the clock *now.
+func newTestBreaker(opt *BreakerOpt, now *time.Time) *Breaker {
+	b := NewBreaker(opt)
+	b.now = func() time.Time { return *now }
+	b.start = *now
+	return b
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var changes []string
+
+	b := newTestBreaker(&BreakerOpt{
+		MinCalls:    4,
+		FailureRate: 0.5,
+		OpenFor:     time.Minute,
+		Trials:      2,
+		OnStateChange: func(from, to BreakerState) {
+			changes = append(changes, from.String()+">"+to.String())
+		},
+	}, &now)
+
+	errFail := errors.New("fail")
+	ok := func() error { return nil }
+	fail := func() error { return errFail }
+
+	// 1 of 3 failures: below MinCalls
+	b.Do(ok)
+	b.Do(fail)
+	b.Do(ok)
+	if b.State() != BreakerClosed {
+		t.Fatalf("tripped before MinCalls")
+	}
+
+	// 2 of 4 trips the breaker
+	if err := b.Do(fail); err != errFail {
+		t.Fatalf("want errFail, got %v", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("want open, got %s", b.State())
+	}
+
+	if err := b.Do(ok); err != ErrBreakerOpen {
+		t.Errorf("want ErrBreakerOpen, got %v", err)
+	}
+
+	// after OpenFor, a failed trial re-opens the breaker
+	now = now.Add(time.Minute)
+	b.Do(fail)
+	if b.State() != BreakerOpen {
+		t.Fatalf("want open after failed trial, got %s", b.State())
+	}
+
+	// two successful trials close it; only Trials calls may be in flight
+	now = now.Add(time.Minute)
+	d1, err := b.Allow()
+	if err != nil {
+		t.Fatalf("trial 1: %s", err)
+	}
+	d2, err := b.Allow()
+	if err != nil {
+		t.Fatalf("trial 2: %s", err)
+	}
+	if _, err := b.Allow(); err != ErrBreakerOpen {
+		t.Errorf("trial 3: want ErrBreakerOpen, got %v", err)
+	}
+	d1(nil)
+	d2(nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("want closed, got %s", b.State())
+	}
+
+	want := "closed>open open>half-open half-open>open open>half-open half-open>closed"
+	got := ""
+	for i, c := range changes {
+		if i > 0 {
+			got += " "
+		}
+		got += c
+	}
+	if got != want {
+		t.Errorf("transitions:\nwant %s\ngot  %s", want, got)
+	}
+}
+
+func TestBreakerSlowCalls(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&BreakerOpt{MinCalls: 2, SlowCall: time.Second}, &now)
+
+	for i := 0; i < 2; i++ {
+		b.Do(func() error {
+			now = now.Add(2 * time.Second)
+			return nil
+		})
+	}
+	if b.State() != BreakerOpen {
+		t.Errorf("slow calls should trip the breaker, got %s", b.State())
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&BreakerOpt{MinCalls: 2, Window: time.Second}, &now)
+
+	b.Do(func() error { return errors.New("x") })
+	now = now.Add(2 * time.Second)
+	b.Do(func() error { return nil })
+	b.Do(func() error { return nil })
+	if b.State() != BreakerClosed {
+		t.Errorf("failures from an old window tripped the breaker")
+	}
+}
+
+func TestBreakerStaleOutcome(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&BreakerOpt{MinCalls: 2, Window: time.Second}, &now)
+
+	// a call that spans a window reset is not counted in the new window
+	done1, _ := b.Allow()
+	now = now.Add(2 * time.Second)
+	done2, _ := b.Allow()
+	done1(errors.New("x"))
+	done2(nil)
+	if b.State() != BreakerClosed {
+		t.Errorf("an outcome from the old window tripped the breaker")
+	}
+}
+
+func TestBreakerPanic(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTestBreaker(&BreakerOpt{MinCalls: 1, OpenFor: time.Minute}, &now)
+
+	b.Do(func() error { return errors.New("x") })
+	now = now.Add(time.Minute)
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("want the panic to propagate, got %v", v)
+			}
+		}()
+		b.Do(func() error { panic("boom") })
+	}()
+
+	// the panicking trial counts as a failure and doesn't leak its slot
+	if b.State() != BreakerOpen {
+		t.Fatalf("want open after a panicking trial, got %s", b.State())
+	}
+	now = now.Add(time.Minute)
+	if err := b.Do(func() error { return nil }); err != nil {
+		t.Fatalf("trial after panic: %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("want closed, got %s", b.State())
+	}
+}
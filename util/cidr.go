@@ -0,0 +1,203 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"net/netip"
+	"sync"
+)
+
+// CIDRTrie maps IP networks to values and finds the longest (most
+// specific) network containing an address. IPv4 and IPv6 networks are
+// kept in separate tries; IPv4-mapped IPv6 addresses are treated as IPv4.
+// It is safe for concurrent use.
+type CIDRTrie[V any] struct {
+	mu     sync.RWMutex
+	v4, v6 cidrNode[V]
+	n      int
+}
+
+type cidrNode[V any] struct {
+	child [2]*cidrNode[V]
+	val   V
+	set   bool
+}
+
+// NewCIDRTrie returns an empty trie.
+func NewCIDRTrie[V any]() *CIDRTrie[V] {
+	return &CIDRTrie[V]{}
+}
+
+// Insert maps the network p to v, replacing any previous value. Host bits
+// in p are ignored. It returns false if p is invalid.
+func (t *CIDRTrie[V]) Insert(p netip.Prefix, v V) bool {
+	p, ok := canonPrefix(p)
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.root(p.Addr())
+	b := p.Addr().AsSlice()
+	for i := 0; i < p.Bits(); i++ {
+		bit := addrBit(b, i)
+		if n.child[bit] == nil {
+			n.child[bit] = &cidrNode[V]{}
+		}
+		n = n.child[bit]
+	}
+
+	if !n.set {
+		t.n++
+	}
+	n.val, n.set = v, true
+	return true
+}
+
+// Remove deletes the network p and reports whether it was present.
+func (t *CIDRTrie[V]) Remove(p netip.Prefix) bool {
+	p, ok := canonPrefix(p)
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// remember the path so that empty nodes can be pruned
+	path := make([]*cidrNode[V], 0, p.Bits()+1)
+	n := t.root(p.Addr())
+	b := p.Addr().AsSlice()
+	for i := 0; i < p.Bits() && n != nil; i++ {
+		path = append(path, n)
+		n = n.child[addrBit(b, i)]
+	}
+	if n == nil || !n.set {
+		return false
+	}
+
+	var zero V
+	n.val, n.set = zero, false
+	t.n--
+
+	for i := len(path) - 1; i >= 0; i-- {
+		if n.set || n.child[0] != nil || n.child[1] != nil {
+			break
+		}
+		path[i].child[addrBit(b, i)] = nil
+		n = path[i]
+	}
+	return true
+}
+
+// Get returns the value stored for exactly the network p.
+func (t *CIDRTrie[V]) Get(p netip.Prefix) (V, bool) {
+	var zero V
+
+	p, ok := canonPrefix(p)
+	if !ok {
+		return zero, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	n := t.root(p.Addr())
+	b := p.Addr().AsSlice()
+	for i := 0; i < p.Bits() && n != nil; i++ {
+		n = n.child[addrBit(b, i)]
+	}
+	if n == nil || !n.set {
+		return zero, false
+	}
+	return n.val, true
+}
+
+// Lookup returns the value and network of the longest prefix containing
+// a.
+func (t *CIDRTrie[V]) Lookup(a netip.Addr) (V, netip.Prefix, bool) {
+	var zero V
+
+	a = a.Unmap()
+	if !a.IsValid() {
+		return zero, netip.Prefix{}, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var best *cidrNode[V]
+	bits := -1
+
+	n := t.root(a)
+	b := a.AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.set {
+			best, bits = n, i
+		}
+		if i == a.BitLen() {
+			break
+		}
+		n = n.child[addrBit(b, i)]
+	}
+
+	if best == nil {
+		return zero, netip.Prefix{}, false
+	}
+	p, _ := a.Prefix(bits)
+	return best.val, p, true
+}
+
+// Contains reports whether any network in the trie contains a.
+func (t *CIDRTrie[V]) Contains(a netip.Addr) bool {
+	_, _, ok := t.Lookup(a)
+	return ok
+}
+
+// Len returns the number of networks in the trie.
+func (t *CIDRTrie[V]) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.n
+}
+
+func (t *CIDRTrie[V]) root(a netip.Addr) *cidrNode[V] {
+	if a.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+// canonPrefix unmaps IPv4-mapped networks and clears the host bits.
+func canonPrefix(p netip.Prefix) (netip.Prefix, bool) {
+	if !p.IsValid() {
+		return p, false
+	}
+
+	a := p.Addr()
+	if a.Is4In6() {
+		bits := p.Bits() - 96
+		if bits < 0 {
+			return p, false
+		}
+		p = netip.PrefixFrom(a.Unmap(), bits)
+	}
+	return p.Masked(), true
+}
+
+func addrBit(b []byte, i int) int {
+	return int(b[i/8]>>(7-i%8)) & 1
+}
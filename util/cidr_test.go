@@ -0,0 +1,78 @@
+package util
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestCIDRTrie(t *testing.T) {
+	tr := NewCIDRTrie[string]()
+	for p, v := range map[string]string{
+		"10.0.0.0/8":      "ten",
+		"10.1.0.0/16":     "ten-one",
+		"10.1.2.3/32":     "host",
+		"0.0.0.0/0":       "default4",
+		"2001:db8::/32":   "doc",
+		"2001:db8:1::/48": "doc-1",
+	} {
+		if !tr.Insert(netip.MustParsePrefix(p), v) {
+			t.Fatalf("insert %s failed", p)
+		}
+	}
+	if tr.Len() != 6 {
+		t.Fatalf("want 6 networks, got %d", tr.Len())
+	}
+
+	tests := []struct {
+		addr, want, net string
+	}{
+		{"10.1.2.3", "host", "10.1.2.3/32"},
+		{"10.1.2.4", "ten-one", "10.1.0.0/16"},
+		{"10.2.0.1", "ten", "10.0.0.0/8"},
+		{"192.168.1.1", "default4", "0.0.0.0/0"},
+		{"::ffff:10.1.9.9", "ten-one", "10.1.0.0/16"},
+		{"2001:db8:1::5", "doc-1", "2001:db8:1::/48"},
+		{"2001:db8:2::5", "doc", "2001:db8::/32"},
+	}
+	for _, tc := range tests {
+		v, p, ok := tr.Lookup(netip.MustParseAddr(tc.addr))
+		if !ok || v != tc.want || p.String() != tc.net {
+			t.Errorf("%s: want %s %s, got %s %s %v", tc.addr, tc.want, tc.net, v, p, ok)
+		}
+	}
+
+	if tr.Contains(netip.MustParseAddr("2001:db9::1")) {
+		t.Errorf("2001:db9::1 should not match")
+	}
+
+	// host bits are ignored
+	if v, ok := tr.Get(netip.MustParsePrefix("10.1.200.1/16")); !ok || v != "ten-one" {
+		t.Errorf("get 10.1/16: got %s %v", v, ok)
+	}
+}
+
+func TestCIDRTrieRemove(t *testing.T) {
+	tr := NewCIDRTrie[int]()
+	tr.Insert(netip.MustParsePrefix("192.168.0.0/16"), 1)
+	tr.Insert(netip.MustParsePrefix("192.168.1.0/24"), 2)
+
+	if tr.Remove(netip.MustParsePrefix("192.168.2.0/24")) {
+		t.Errorf("removed a network that isn't there")
+	}
+	if !tr.Remove(netip.MustParsePrefix("192.168.1.0/24")) {
+		t.Fatalf("remove failed")
+	}
+	if tr.v4.child[1] == nil {
+		t.Fatalf("pruned too much")
+	}
+
+	v, _, ok := tr.Lookup(netip.MustParseAddr("192.168.1.1"))
+	if !ok || v != 1 {
+		t.Errorf("want fallback to /16, got %d %v", v, ok)
+	}
+
+	tr.Remove(netip.MustParsePrefix("192.168.0.0/16"))
+	if tr.Len() != 0 || tr.v4.child[0] != nil || tr.v4.child[1] != nil {
+		t.Errorf("trie not empty after removing everything")
+	}
+}
@@ -0,0 +1,263 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnPoolOpt configures a connection pool. Zero values select the
+// defaults noted below.
+type ConnPoolOpt struct {
+	// MaxActive limits the connections checked out at once; Get blocks
+	// when it is reached. Zero means no limit.
+	MaxActive int
+
+	// MaxIdle is the number of idle connections kept for reuse.
+	// Default 2.
+	MaxIdle int
+
+	// IdleTimeout closes connections idle for longer than this. Zero
+	// keeps them forever.
+	IdleTimeout time.Duration
+
+	// HealthCheck, if set, is called on an idle connection before it is
+	// handed out; on error the connection is closed and another one is
+	// tried.
+	HealthCheck func(c net.Conn) error
+}
+
+// ConnPool reuses connections to one endpoint. It is safe for concurrent
+// use.
+type ConnPool struct {
+	opt  ConnPoolOpt
+	dial func(ctx context.Context) (net.Conn, error)
+	sem  *Semaphore
+
+	mu     sync.Mutex
+	idle   []idleConn // most recently used last
+	closed bool
+	now    func() time.Time
+
+	// done is cancelled by Close to stop the reaper and release Get
+	// callers waiting for a slot
+	done   context.Context
+	cancel context.CancelFunc
+}
+
+type idleConn struct {
+	c     net.Conn
+	since time.Time
+}
+
+// PoolConn is a connection checked out of a ConnPool. Close returns it
+// to the pool; call Discard instead if it is broken.
+type PoolConn struct {
+	net.Conn
+	p    *ConnPool
+	once sync.Once
+}
+
+// NewConnPool returns a pool that opens connections with dial.
+func NewConnPool(dial func(ctx context.Context) (net.Conn, error), opt *ConnPoolOpt) *ConnPool {
+	var o ConnPoolOpt
+	if opt != nil {
+		o = *opt
+	}
+	if o.MaxIdle <= 0 {
+		o.MaxIdle = 2
+	}
+
+	p := &ConnPool{
+		opt:  o,
+		dial: dial,
+		now:  time.Now,
+	}
+	p.done, p.cancel = context.WithCancel(context.Background())
+	if o.MaxActive > 0 {
+		p.sem = NewSemaphore(int64(o.MaxActive))
+	}
+	if o.IdleTimeout > 0 {
+		go p.reaper()
+	}
+	return p
+}
+
+// Get returns an idle connection or dials a new one, waiting for a free
+// slot if MaxActive connections are in use. Waiting callers get
+// net.ErrClosed if the pool is closed.
+func (p *ConnPool) Get(ctx context.Context) (*PoolConn, error) {
+	if p.sem != nil && !p.sem.TryAcquire(1) {
+		if err := p.acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.release()
+			return nil, net.ErrClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		ic := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if p.expired(ic) || (p.opt.HealthCheck != nil && p.opt.HealthCheck(ic.c) != nil) {
+			ic.c.Close()
+			continue
+		}
+		return &PoolConn{Conn: ic.c, p: p}, nil
+	}
+
+	c, err := p.dial(ctx)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return &PoolConn{Conn: c, p: p}, nil
+}
+
+// Idle returns the number of idle connections.
+func (p *ConnPool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close closes the idle connections; connections still checked out are
+// closed when they are returned.
+func (p *ConnPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.cancel()
+	p.mu.Unlock()
+
+	for _, ic := range idle {
+		ic.c.Close()
+	}
+	return nil
+}
+
+// Close returns the connection to the pool. It is safe to call more than
+// once.
+func (pc *PoolConn) Close() error {
+	var err error
+	pc.once.Do(func() {
+		err = pc.p.put(pc.Conn)
+	})
+	return err
+}
+
+// Discard closes the underlying connection instead of returning it.
+func (pc *PoolConn) Discard() error {
+	var err error
+	pc.once.Do(func() {
+		err = pc.Conn.Close()
+		pc.p.release()
+	})
+	return err
+}
+
+func (p *ConnPool) put(c net.Conn) error {
+	defer p.release()
+
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.opt.MaxIdle {
+		p.mu.Unlock()
+		return c.Close()
+	}
+	p.idle = append(p.idle, idleConn{c, p.now()})
+	p.mu.Unlock()
+	return nil
+}
+
+// acquire waits for a slot until ctx is done or the pool is closed.
+func (p *ConnPool) acquire(ctx context.Context) error {
+	actx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.done, cancel)
+	defer stop()
+
+	err := p.sem.Acquire(actx, 1)
+	if err != nil && ctx.Err() == nil {
+		return net.ErrClosed
+	}
+	return err
+}
+
+func (p *ConnPool) release() {
+	if p.sem != nil {
+		p.sem.Release(1)
+	}
+}
+
+func (p *ConnPool) expired(ic idleConn) bool {
+	return p.opt.IdleTimeout > 0 && p.now().Sub(ic.since) > p.opt.IdleTimeout
+}
+
+// connReapMin bounds how often the reaper runs for tiny idle timeouts;
+// Get checks the timeout itself, so a late reap only delays closing.
+const connReapMin = time.Second
+
+// reaper periodically closes connections that have been idle too long.
+func (p *ConnPool) reaper() {
+	t := time.NewTicker(max(p.opt.IdleTimeout/2, connReapMin))
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			p.reap()
+		case <-p.done.Done():
+			return
+		}
+	}
+}
+
+func (p *ConnPool) reap() {
+	var stale []net.Conn
+
+	p.mu.Lock()
+	keep := p.idle[:0]
+	for _, ic := range p.idle {
+		if p.expired(ic) {
+			stale = append(stale, ic.c)
+		} else {
+			keep = append(keep, ic)
+		}
+	}
+	clear(p.idle[len(keep):])
+	p.idle = keep
+	p.mu.Unlock()
+
+	for _, c := range stale {
+		c.Close()
+	}
+}
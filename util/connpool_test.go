@@ -0,0 +1,162 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pipeDialer returns a dialer of in-memory connections and a count of
+// dials.
+func pipeDialer() (func(context.Context) (net.Conn, error), *int32) {
+	var n int32
+	return func(context.Context) (net.Conn, error) {
+		atomic.AddInt32(&n, 1)
+		a, b := net.Pipe()
+		go func() {
+			// drain the far end until it is closed
+			buf := make([]byte, 64)
+			for {
+				if _, err := b.Read(buf); err != nil {
+					return
+				}
+			}
+		}()
+		return a, nil
+	}, &n
+}
+
+func TestConnPool(t *testing.T) {
+	dial, dials := pipeDialer()
+	p := NewConnPool(dial, &ConnPoolOpt{MaxIdle: 1})
+	defer p.Close()
+
+	ctx := context.Background()
+	c1, _ := p.Get(ctx)
+	c2, _ := p.Get(ctx)
+	c1.Close()
+	c2.Close()
+	c2.Close()
+
+	if p.Idle() != 1 {
+		t.Fatalf("want 1 idle connection, got %d", p.Idle())
+	}
+
+	c3, _ := p.Get(ctx)
+	if c3.Conn != c1.Conn {
+		t.Errorf("idle connection not reused")
+	}
+	if *dials != 2 {
+		t.Errorf("want 2 dials, got %d", *dials)
+	}
+
+	c3.Discard()
+	if p.Idle() != 0 {
+		t.Errorf("discarded connection returned to the pool")
+	}
+}
+
+func TestConnPoolMaxActive(t *testing.T) {
+	dial, _ := pipeDialer()
+	p := NewConnPool(dial, &ConnPoolOpt{MaxActive: 1})
+	defer p.Close()
+
+	c, _ := p.Get(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+
+	c.Close()
+	c2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("get after release: %s", err)
+	}
+	c2.Close()
+}
+
+func TestConnPoolCloseWaiting(t *testing.T) {
+	dial, _ := pipeDialer()
+	p := NewConnPool(dial, &ConnPoolOpt{MaxActive: 1})
+
+	c, _ := p.Get(context.Background())
+	defer c.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("want net.ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Get still waiting after Close")
+	}
+}
+
+func TestConnPoolTinyIdleTimeout(t *testing.T) {
+	dial, _ := pipeDialer()
+	p := NewConnPool(dial, &ConnPoolOpt{IdleTimeout: time.Nanosecond})
+	defer p.Close()
+
+	// give the reaper time to start its ticker
+	time.Sleep(10 * time.Millisecond)
+
+	c, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	c.Close()
+}
+
+func TestConnPoolHealthAndIdle(t *testing.T) {
+	dial, dials := pipeDialer()
+	healthy := true
+	p := NewConnPool(dial, &ConnPoolOpt{
+		IdleTimeout: time.Hour,
+		HealthCheck: func(net.Conn) error {
+			if !healthy {
+				return errors.New("dead")
+			}
+			return nil
+		},
+	})
+	defer p.Close()
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	c, _ := p.Get(ctx)
+	c.Close()
+	healthy = false
+	c, _ = p.Get(ctx)
+	if *dials != 2 {
+		t.Errorf("unhealthy connection reused")
+	}
+	healthy = true
+	c.Close()
+
+	// expire the idle connection and reap it
+	now = now.Add(2 * time.Hour)
+	p.reap()
+	if p.Idle() != 0 {
+		t.Errorf("idle connection not reaped")
+	}
+
+	p.Close()
+	if _, err := p.Get(ctx); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("want ErrClosed, got %v", err)
+	}
+}
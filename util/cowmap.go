@@ -0,0 +1,106 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"iter"
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// COWMap is a copy-on-write map for data that is read on hot paths and
+// changed rarely. Reads load an immutable snapshot without locking;
+// writes copy the map, modify the copy and swap it in, so each write
+// costs O(n). The zero value is an empty map ready to use.
+type COWMap[K comparable, V any] struct {
+	mu sync.Mutex // serializes writers
+	p  atomic.Pointer[map[K]V]
+}
+
+// NewCOWMap returns a map holding a copy of m.
+func NewCOWMap[K comparable, V any](m map[K]V) *COWMap[K, V] {
+	c := new(COWMap[K, V])
+	c.Replace(m)
+	return c
+}
+
+func (c *COWMap[K, V]) load() map[K]V {
+	if p := c.p.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// Get returns the value for k.
+func (c *COWMap[K, V]) Get(k K) (V, bool) {
+	v, ok := c.load()[k]
+	return v, ok
+}
+
+// Len returns the number of entries.
+func (c *COWMap[K, V]) Len() int {
+	return len(c.load())
+}
+
+// Snapshot returns the current map. It must not be modified; later
+// writes to c don't affect it.
+func (c *COWMap[K, V]) Snapshot() map[K]V {
+	return c.load()
+}
+
+// All iterates over a snapshot of the entries in no particular order.
+func (c *COWMap[K, V]) All() iter.Seq2[K, V] {
+	return maps.All(c.load())
+}
+
+// Set sets the value for k.
+func (c *COWMap[K, V]) Set(k K, v V) {
+	c.Update(func(m map[K]V) { m[k] = v })
+}
+
+// Delete removes k.
+func (c *COWMap[K, V]) Delete(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.load()[k]; ok {
+		m := maps.Clone(c.load())
+		delete(m, k)
+		c.p.Store(&m)
+	}
+}
+
+// Update calls fn with a private copy of the map and publishes the
+// result, so several changes are made with a single copy and become
+// visible at once.
+func (c *COWMap[K, V]) Update(fn func(m map[K]V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := maps.Clone(c.load())
+	if m == nil {
+		m = make(map[K]V)
+	}
+	fn(m)
+	c.p.Store(&m)
+}
+
+// Replace replaces the contents with a copy of m.
+func (c *COWMap[K, V]) Replace(m map[K]V) {
+	m = maps.Clone(m)
+	c.mu.Lock()
+	c.p.Store(&m)
+	c.mu.Unlock()
+}
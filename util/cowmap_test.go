@@ -0,0 +1,79 @@
+package util
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCOWMap(t *testing.T) {
+	var c COWMap[string, int]
+	if _, ok := c.Get("x"); ok || c.Len() != 0 {
+		t.Fatal("zero map not empty")
+	}
+
+	c.Set("a", 1)
+	snap := c.Snapshot()
+	c.Set("b", 2)
+	c.Delete("a")
+	c.Delete("missing")
+
+	if len(snap) != 1 || snap["a"] != 1 {
+		t.Errorf("snapshot changed: %v", snap)
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 || c.Len() != 1 {
+		t.Errorf("want b=2 only, got %v", c.Snapshot())
+	}
+
+	c.Update(func(m map[string]int) {
+		m["c"] = 3
+		m["d"] = 4
+	})
+	n := 0
+	for _, v := range c.All() {
+		n += v
+	}
+	if n != 9 {
+		t.Errorf("want sum 9, got %d", n)
+	}
+
+	src := map[string]int{"x": 1}
+	c2 := NewCOWMap(src)
+	src["y"] = 2
+	if c2.Len() != 1 {
+		t.Errorf("NewCOWMap didn't copy")
+	}
+	c2.Replace(nil)
+	if c2.Len() != 0 {
+		t.Errorf("Replace(nil) left %d entries", c2.Len())
+	}
+}
+
+func TestCOWMapConcurrent(t *testing.T) {
+	var c COWMap[int, string]
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				c.Set(w*100+i, fmt.Sprint(i))
+			}
+		}()
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				c.Get(i % 400)
+				c.Len()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.Len() != 400 {
+		t.Errorf("want 400 entries, got %d", c.Len())
+	}
+}
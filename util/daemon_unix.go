@@ -0,0 +1,251 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build unix && !aix && !solaris
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrRunning is returned by WritePidfile when another live process holds
+// the pidfile.
+var ErrRunning = errors.New("daemon: already running")
+
+// Pidfile is a locked pidfile; the lock is held until Close or process
+// exit, so a crashed daemon never leaves a pidfile that blocks a restart.
+type Pidfile struct {
+	fd *os.File
+}
+
+// WritePidfile locks fn and writes the current pid to it. If another
+// process holds the lock, the error wraps ErrRunning and names its pid. A
+// leftover pidfile from a dead process is taken over.
+func WritePidfile(fn string) (*Pidfile, error) {
+	fd, err := lockPidfile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	// we own the lock; replace whatever stale content is there
+	pid := strconv.Itoa(os.Getpid()) + "\n"
+	if err := fd.Truncate(0); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if _, err := fd.WriteAt([]byte(pid), 0); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if err := fd.Sync(); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	return &Pidfile{fd: fd}, nil
+}
+
+// lockPidfile opens and locks fn. The previous owner unlinks the file
+// before unlocking it, so a lock obtained on a file that is no longer at
+// fn is worthless; retry with the current one.
+func lockPidfile(fn string) (*os.File, error) {
+	for {
+		fd, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := syscall.Flock(int(fd.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			pid, _ := readPid(fd)
+			fd.Close()
+			if err == syscall.EWOULDBLOCK {
+				return nil, fmt.Errorf("%s: pid %d: %w", fn, pid, ErrRunning)
+			}
+			return nil, fmt.Errorf("%s: lock: %w", fn, err)
+		}
+
+		if samePidfile(fd) {
+			return fd, nil
+		}
+		fd.Close()
+	}
+}
+
+// samePidfile reports whether fd is still the file at its path.
+func samePidfile(fd *os.File) bool {
+	fi, err := fd.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(fd.Name())
+	return err == nil && os.SameFile(fi, pi)
+}
+
+// Close removes the pidfile and releases the lock. The file is removed
+// while still locked, and only if it is still ours.
+func (p *Pidfile) Close() error {
+	if p.fd == nil {
+		return nil
+	}
+
+	var err error
+	if samePidfile(p.fd) {
+		err = os.Remove(p.fd.Name())
+	}
+	if cerr := p.fd.Close(); err == nil {
+		err = cerr
+	}
+	p.fd = nil
+	return err
+}
+
+// ReadPidfile returns the pid stored in fn and whether that process is
+// still running. A pidfile whose process is gone is stale.
+func ReadPidfile(fn string) (pid int, running bool, err error) {
+	fd, err := os.Open(fn)
+	if err != nil {
+		return 0, false, err
+	}
+	defer fd.Close()
+
+	if pid, err = readPid(fd); err != nil {
+		return 0, false, fmt.Errorf("%s: %w", fn, err)
+	}
+	return pid, processAlive(pid), nil
+}
+
+func readPid(fd *os.File) (int, error) {
+	var b [32]byte
+	n, err := fd.ReadAt(b[:], 0)
+	if n == 0 && err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b[:n])))
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// Chroot changes the root directory to dir and moves into it. Open
+// listeners and files remain usable; anything else must be opened
+// before.
+func Chroot(dir string) error {
+	if err := syscall.Chroot(dir); err != nil {
+		return fmt.Errorf("chroot %s: %w", dir, err)
+	}
+	return os.Chdir("/")
+}
+
+// DropPrivs switches to the given user and group, typically after
+// binding privileged ports. An empty group selects the user's primary
+// group. Supplementary groups are cleared.
+func DropPrivs(usr, group string) error {
+	u, err := user.Lookup(usr)
+	if err != nil {
+		return err
+	}
+
+	gid := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return err
+		}
+		gid = g.Gid
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("user %s: bad uid %q", usr, u.Uid)
+	}
+	ngid, err := strconv.Atoi(gid)
+	if err != nil {
+		return fmt.Errorf("group %s: bad gid %q", group, gid)
+	}
+
+	// order matters: once the uid is dropped we can't change groups
+	if err := syscall.Setgroups([]int{ngid}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(ngid); err != nil {
+		return fmt.Errorf("setgid %d: %w", ngid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid %d: %w", uid, err)
+	}
+	return nil
+}
+
+// reexecEnv passes the number of inherited files to the new process.
+const reexecEnv = "GOLIB_REEXEC_FDS"
+
+// Reexec starts a new copy of the running executable with the same
+// arguments, handing it files (typically listener sockets obtained via
+// File()) as descriptors 3, 4, ... The caller decides when to exit;
+// the new process finds the files with InheritedFiles.
+func Reexec(files []*os.File) (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, reexecEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	env = append(env, fmt.Sprintf("%s=%d", reexecEnv, len(files)))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	attr := &os.ProcAttr{
+		Dir:   wd,
+		Env:   env,
+		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...),
+	}
+	return os.StartProcess(exe, os.Args, attr)
+}
+
+// InheritedFiles returns the files passed by a parent's Reexec, or nil if
+// the process wasn't started that way. It clears the marker so that the
+// files aren't claimed twice.
+func InheritedFiles() []*os.File {
+	n, err := strconv.Atoi(os.Getenv(reexecEnv))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	os.Unsetenv(reexecEnv)
+
+	r := make([]*os.File, n)
+	for i := range r {
+		fd := uintptr(3 + i)
+		syscall.CloseOnExec(int(fd))
+		r[i] = os.NewFile(fd, fmt.Sprintf("inherited-%d", i))
+	}
+	return r
+}
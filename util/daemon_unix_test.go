@@ -0,0 +1,82 @@
+//go:build unix && !aix && !solaris
+
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestPidfile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.pid")
+
+	p, err := WritePidfile(fn)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	pid, running, err := ReadPidfile(fn)
+	if err != nil || pid != os.Getpid() || !running {
+		t.Fatalf("read: got %d %v %v", pid, running, err)
+	}
+
+	// a second lock fails even from the same process
+	if _, err := WritePidfile(fn); !errors.Is(err, ErrRunning) {
+		t.Errorf("want ErrRunning, got %v", err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	if _, err := os.Stat(fn); !os.IsNotExist(err) {
+		t.Errorf("pidfile not removed")
+	}
+}
+
+func TestPidfileStale(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "stale.pid")
+
+	// pids this large are never allocated
+	os.WriteFile(fn, []byte(strconv.Itoa(1<<30)+"\n"), 0644)
+	if _, running, err := ReadPidfile(fn); err != nil || running {
+		t.Fatalf("want stale pidfile, got running=%v %v", running, err)
+	}
+
+	p, err := WritePidfile(fn)
+	if err != nil {
+		t.Fatalf("take over stale pidfile: %s", err)
+	}
+	defer p.Close()
+
+	b, _ := os.ReadFile(fn)
+	if string(b) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("bad pidfile content: %q", b)
+	}
+}
+
+func TestPidfileReplaced(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "test.pid")
+
+	p, err := WritePidfile(fn)
+	if err != nil {
+		t.Fatalf("write: %s", err)
+	}
+
+	// someone else's pidfile now lives at fn; Close must leave it alone
+	os.Remove(fn)
+	q, err := WritePidfile(fn)
+	if err != nil {
+		t.Fatalf("write replacement: %s", err)
+	}
+	defer q.Close()
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	if _, err := os.Stat(fn); err != nil {
+		t.Errorf("Close removed a pidfile it doesn't own: %s", err)
+	}
+}
@@ -0,0 +1,179 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// CycleError is returned by DAG.Sort when the graph has a cycle.
+type CycleError[T comparable] struct {
+	// Cycle lists the nodes of one cycle; the last node has an edge
+	// back to the first.
+	Cycle []T
+}
+
+func (e *CycleError[T]) Error() string {
+	s := make([]string, len(e.Cycle)+1)
+	for i, n := range e.Cycle {
+		s[i] = fmt.Sprint(n)
+	}
+	s[len(e.Cycle)] = s[0]
+	return "dag: cycle: " + strings.Join(s, " -> ")
+}
+
+// DAG is a directed graph of dependencies that can be sorted
+// topologically. The sort is stable: nodes without an ordering
+// constraint between them keep the order in which they were added. It is
+// not safe for concurrent use.
+type DAG[T comparable] struct {
+	nodes []T
+	idx   map[T]int
+	out   [][]int // edges by node index
+}
+
+// NewDAG returns an empty graph.
+func NewDAG[T comparable]() *DAG[T] {
+	return &DAG[T]{idx: make(map[T]int)}
+}
+
+// AddNode adds n if it isn't in the graph yet.
+func (d *DAG[T]) AddNode(n T) {
+	d.node(n)
+}
+
+// AddEdge adds from and to as needed and records that from must come
+// before to.
+func (d *DAG[T]) AddEdge(from, to T) {
+	f, t := d.node(from), d.node(to)
+	d.out[f] = append(d.out[f], t)
+}
+
+// Len returns the number of nodes.
+func (d *DAG[T]) Len() int {
+	return len(d.nodes)
+}
+
+func (d *DAG[T]) node(n T) int {
+	if i, ok := d.idx[n]; ok {
+		return i
+	}
+	i := len(d.nodes)
+	d.nodes = append(d.nodes, n)
+	d.out = append(d.out, nil)
+	d.idx[n] = i
+	return i
+}
+
+// Sort returns the nodes so that every edge goes from an earlier node to
+// a later one. If the graph has a cycle, the error is a *CycleError.
+func (d *DAG[T]) Sort() ([]T, error) {
+	indeg := make([]int, len(d.nodes))
+	for _, o := range d.out {
+		for _, t := range o {
+			indeg[t]++
+		}
+	}
+
+	// Kahn's algorithm with the ready set ordered by insertion index
+	var ready intHeap
+	for i, n := range indeg {
+		if n == 0 {
+			ready = append(ready, i)
+		}
+	}
+	heap.Init(&ready)
+
+	r := make([]T, 0, len(d.nodes))
+	for ready.Len() > 0 {
+		i := heap.Pop(&ready).(int)
+		r = append(r, d.nodes[i])
+		for _, t := range d.out[i] {
+			if indeg[t]--; indeg[t] == 0 {
+				heap.Push(&ready, t)
+			}
+		}
+	}
+
+	if len(r) < len(d.nodes) {
+		return nil, &CycleError[T]{Cycle: d.findCycle(indeg)}
+	}
+	return r, nil
+}
+
+// findCycle returns a cycle among the nodes Kahn's algorithm couldn't
+// place (those with a remaining in-degree).
+func (d *DAG[T]) findCycle(indeg []int) []T {
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := make([]int, len(d.nodes))
+	var stack []int
+	var cycle []T
+
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		color[i] = grey
+		stack = append(stack, i)
+		for _, t := range d.out[i] {
+			switch color[t] {
+			case grey:
+				// unwind the stack back to t
+				for k := len(stack) - 1; k >= 0; k-- {
+					if stack[k] == t {
+						for _, j := range stack[k:] {
+							cycle = append(cycle, d.nodes[j])
+						}
+						return true
+					}
+				}
+			case white:
+				if visit(t) {
+					return true
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[i] = black
+		return false
+	}
+
+	for i := range d.nodes {
+		if indeg[i] > 0 && color[i] == white && visit(i) {
+			break
+		}
+	}
+	return cycle
+}
+
+type intHeap []int
+
+func (h intHeap) Len() int           { return len(h) }
+func (h intHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *intHeap) Push(x interface{}) {
+	*h = append(*h, x.(int))
+}
+
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDAGSort(t *testing.T) {
+	d := NewDAG[string]()
+	d.AddNode("logger")
+	d.AddNode("metrics")
+	d.AddEdge("listeners", "pool")
+	d.AddEdge("pool", "logger")
+	d.AddEdge("listeners", "metrics")
+	d.AddNode("pool")
+
+	got, err := d.Sort()
+	if err != nil {
+		t.Fatalf("sort: %s", err)
+	}
+
+	// listeners has no predecessors and is placed as soon as possible;
+	// ties are broken by insertion order
+	want := []string{"listeners", "metrics", "pool", "logger"}
+	if !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	// stable across runs
+	for i := 0; i < 10; i++ {
+		if again, _ := d.Sort(); !slices.Equal(again, got) {
+			t.Fatalf("unstable order: %v vs %v", again, got)
+		}
+	}
+}
+
+func TestDAGCycle(t *testing.T) {
+	d := NewDAG[int]()
+	d.AddEdge(0, 1)
+	d.AddEdge(1, 2)
+	d.AddEdge(2, 3)
+	d.AddEdge(3, 1)
+	d.AddEdge(3, 4)
+
+	_, err := d.Sort()
+	var ce *CycleError[int]
+	if !errors.As(err, &ce) {
+		t.Fatalf("want CycleError, got %v", err)
+	}
+	if !slices.Equal(ce.Cycle, []int{1, 2, 3}) {
+		t.Errorf("want cycle [1 2 3], got %v", ce.Cycle)
+	}
+	if err.Error() != "dag: cycle: 1 -> 2 -> 3 -> 1" {
+		t.Errorf("bad message: %s", err)
+	}
+
+	self := NewDAG[string]()
+	self.AddEdge("a", "a")
+	if _, err := self.Sort(); err == nil {
+		t.Errorf("self loop not detected")
+	}
+}
@@ -0,0 +1,469 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errDNSMsg is returned for DNS messages we can't parse.
+var errDNSMsg = errors.New("dnscache: malformed message")
+
+// DNSCacheOpt configures a DNSCache. Zero values select the defaults
+// noted below.
+type DNSCacheOpt struct {
+	// Server, if set, receives all queries instead of the servers
+	// from the system configuration ("host:port").
+	Server string
+
+	// MinTTL and MaxTTL clamp the record TTLs. Defaults 0 and 1h.
+	MinTTL, MaxTTL time.Duration
+
+	// NegTTL is used for negative answers (NXDOMAIN, no data) that
+	// don't carry an SOA record. Default 30s.
+	NegTTL time.Duration
+
+	// MaxEntries bounds the cache. Default 4096.
+	MaxEntries int
+}
+
+// DNSCache caches DNS answers for a net.Resolver. It sits between Go's
+// stub resolver and the name servers, so answers expire after their
+// real TTL, negative answers are cached per RFC 2308, and concurrent
+// identical queries are sent upstream once. Use it through Resolver.
+type DNSCache struct {
+	opt    DNSCacheOpt
+	dialer net.Dialer
+	now    func() time.Time
+
+	mu       sync.Mutex
+	m        map[string]*dnsEntry
+	inflight map[string]*dnsCall
+}
+
+type dnsEntry struct {
+	msg     []byte
+	ttls    []dnsTTL
+	stored  time.Time
+	expires time.Time
+}
+
+// dnsTTL is the offset and original value of an RR's TTL field.
+type dnsTTL struct {
+	off int
+	ttl uint32
+}
+
+type dnsCall struct {
+	done chan struct{}
+	msg  []byte
+	err  error
+}
+
+// NewDNSCache returns an empty cache.
+func NewDNSCache(opt *DNSCacheOpt) *DNSCache {
+	var o DNSCacheOpt
+	if opt != nil {
+		o = *opt
+	}
+	if o.MaxTTL <= 0 {
+		o.MaxTTL = time.Hour
+	}
+	if o.NegTTL <= 0 {
+		o.NegTTL = 30 * time.Second
+	}
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = 4096
+	}
+
+	return &DNSCache{
+		opt:      o,
+		now:      time.Now,
+		m:        make(map[string]*dnsEntry),
+		inflight: make(map[string]*dnsCall),
+	}
+}
+
+// Resolver returns a resolver that answers through the cache. It can be
+// used anywhere a *net.Resolver is accepted, e.g. net.Dialer.Resolver.
+func (c *DNSCache) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial:     c.dial,
+	}
+}
+
+// Len returns the number of cached answers, including expired ones not
+// yet evicted.
+func (c *DNSCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.m)
+}
+
+// Purge empties the cache.
+func (c *DNSCache) Purge() {
+	c.mu.Lock()
+	clear(c.m)
+	c.mu.Unlock()
+}
+
+// dial hands the resolver a fake connection that answers from the cache.
+func (c *DNSCache) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.opt.Server != "" {
+		addr = c.opt.Server
+	}
+	dc := &dnsConn{c: c, ctx: ctx, network: network, addr: addr}
+	if strings.HasPrefix(network, "udp") {
+		return &dnsPacketConn{dc}, nil
+	}
+	return dc, nil
+}
+
+// query answers the DNS message q.
+func (c *DNSCache) query(ctx context.Context, network, addr string, q []byte) ([]byte, error) {
+	key, err := dnsKey(q)
+	if err != nil {
+		return nil, err
+	}
+	id := q[:2]
+
+	c.mu.Lock()
+	if e, ok := c.m[key]; ok {
+		if now := c.now(); now.Before(e.expires) {
+			r := e.answer(id, now)
+			c.mu.Unlock()
+			return r, nil
+		}
+		delete(c.m, key)
+	}
+
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		r := bytes.Clone(call.msg)
+		copy(r, id)
+		return r, nil
+	}
+
+	call := &dnsCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.msg, call.err = c.upstream(ctx, network, addr, q)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.store(key, call.msg)
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.msg, call.err
+}
+
+// upstream sends q to the name server and returns its answer.
+func (c *DNSCache) upstream(ctx context.Context, network, addr string, q []byte) ([]byte, error) {
+	conn, err := c.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+
+	if _, ok := conn.(net.PacketConn); ok {
+		if _, err := conn.Write(q); err != nil {
+			return nil, err
+		}
+		b := make([]byte, 65535)
+		for {
+			n, err := conn.Read(b)
+			if err != nil {
+				return nil, err
+			}
+			// ignore stray or forged answers, like the stub resolver
+			if n >= 12 && bytes.Equal(b[:2], q[:2]) {
+				return b[:n:n], nil
+			}
+		}
+	}
+
+	msg := make([]byte, 2+len(q))
+	binary.BigEndian.PutUint16(msg, uint16(len(q)))
+	copy(msg[2:], q)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var l [2]byte
+	if _, err := io.ReadFull(conn, l[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// store caches msg if it is cacheable. It must be called with the lock
+// held.
+func (c *DNSCache) store(key string, msg []byte) {
+	ttl, ttls, ok := c.ttl(msg)
+	if !ok || ttl <= 0 {
+		return
+	}
+
+	if len(c.m) >= c.opt.MaxEntries {
+		now := c.now()
+		for k, e := range c.m {
+			if !now.Before(e.expires) {
+				delete(c.m, k)
+			}
+		}
+		for k := range c.m {
+			if len(c.m) < c.opt.MaxEntries {
+				break
+			}
+			delete(c.m, k)
+		}
+	}
+
+	now := c.now()
+	c.m[key] = &dnsEntry{
+		msg:     msg,
+		ttls:    ttls,
+		stored:  now,
+		expires: now.Add(ttl),
+	}
+}
+
+// answer returns a copy of the cached message with the query's id and
+// the TTLs reduced by the time spent in the cache.
+func (e *dnsEntry) answer(id []byte, now time.Time) []byte {
+	r := bytes.Clone(e.msg)
+	copy(r, id)
+
+	age := uint32(now.Sub(e.stored) / time.Second)
+	for _, t := range e.ttls {
+		binary.BigEndian.PutUint32(r[t.off:], t.ttl-min(age, t.ttl))
+	}
+	return r
+}
+
+const (
+	dnsTypeSOA = 6
+	dnsTypeOPT = 41
+
+	dnsRcodeNXDomain = 3
+	dnsFlagTC        = 0x0200
+)
+
+// dnsKey identifies a query by its lower cased question and the flags
+// that change the answer (RD, AD, CD).
+func dnsKey(q []byte) (string, error) {
+	if len(q) < 12 || binary.BigEndian.Uint16(q[4:]) != 1 {
+		return "", errDNSMsg
+	}
+	end, err := dnsSkipName(q, 12)
+	if err != nil || end+4 > len(q) {
+		return "", errDNSMsg
+	}
+
+	k := make([]byte, 0, 2+end-12+4)
+	k = binary.BigEndian.AppendUint16(k, binary.BigEndian.Uint16(q[2:])&0x0130)
+	k = append(k, bytes.ToLower(q[12:end+4])...)
+	return string(k), nil
+}
+
+// ttl returns how long msg may be cached and the TTL fields to adjust
+// when serving it. Only complete NOERROR and NXDOMAIN answers are
+// cached.
+func (c *DNSCache) ttl(msg []byte) (time.Duration, []dnsTTL, bool) {
+	if len(msg) < 12 {
+		return 0, nil, false
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+	rcode := flags & 0xf
+	if flags&dnsFlagTC != 0 || (rcode != 0 && rcode != dnsRcodeNXDomain) {
+		return 0, nil, false
+	}
+
+	off := 12
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[4:])); i++ {
+		n, err := dnsSkipName(msg, off)
+		if err != nil || n+4 > len(msg) {
+			return 0, nil, false
+		}
+		off = n + 4
+	}
+
+	nan := int(binary.BigEndian.Uint16(msg[6:]))
+	nns := int(binary.BigEndian.Uint16(msg[8:]))
+	nar := int(binary.BigEndian.Uint16(msg[10:]))
+
+	var ttls []dnsTTL
+	minAns, minNeg := uint32(1<<31), uint32(0)
+	haveNeg := false
+	for i := 0; i < nan+nns+nar; i++ {
+		n, err := dnsSkipName(msg, off)
+		if err != nil || n+10 > len(msg) {
+			return 0, nil, false
+		}
+		typ := binary.BigEndian.Uint16(msg[n:])
+		ttl := binary.BigEndian.Uint32(msg[n+4:])
+		rdlen := int(binary.BigEndian.Uint16(msg[n+8:]))
+		rdata := n + 10
+		if rdata+rdlen > len(msg) {
+			return 0, nil, false
+		}
+
+		// the OPT pseudo record's "TTL" holds flags
+		if typ != dnsTypeOPT {
+			ttls = append(ttls, dnsTTL{n + 4, ttl})
+		}
+
+		switch {
+		case i < nan:
+			minAns = min(minAns, ttl)
+		case i < nan+nns && typ == dnsTypeSOA && rdlen >= 20:
+			// RFC 2308: the lesser of the SOA TTL and its MINIMUM
+			soaMin := binary.BigEndian.Uint32(msg[rdata+rdlen-4:])
+			minNeg, haveNeg = min(ttl, soaMin), true
+		}
+		off = rdata + rdlen
+	}
+
+	var d time.Duration
+	switch {
+	case nan > 0 && rcode == 0:
+		d = time.Duration(minAns) * time.Second
+	case haveNeg:
+		d = time.Duration(minNeg) * time.Second
+	default:
+		d = c.opt.NegTTL
+	}
+	return min(max(d, c.opt.MinTTL), c.opt.MaxTTL), ttls, true
+}
+
+// dnsSkipName returns the offset after the (possibly compressed) name at
+// off.
+func dnsSkipName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errDNSMsg
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			return off + 2, nil
+		case l&0xc0 != 0:
+			return 0, errDNSMsg
+		}
+		off += 1 + l
+	}
+}
+
+// dnsConn is the connection handed to the stub resolver: a write sends a
+// query, the following reads return the answer.
+type dnsConn struct {
+	c       *DNSCache
+	ctx     context.Context
+	network string
+	addr    string
+
+	mu  sync.Mutex
+	r   bytes.Reader
+	msg []byte
+}
+
+// dnsPacketConn marks a datagram connection, which the stub resolver
+// reads a whole message at a time.
+type dnsPacketConn struct {
+	*dnsConn
+}
+
+func (d *dnsConn) Write(b []byte) (int, error) {
+	stream := strings.HasPrefix(d.network, "tcp")
+	q := b
+	if stream {
+		if len(b) < 2 || int(binary.BigEndian.Uint16(b)) != len(b)-2 {
+			return 0, errDNSMsg
+		}
+		q = b[2:]
+	}
+
+	msg, err := d.c.query(d.ctx, d.network, d.addr, q)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	if stream {
+		msg = append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...)
+	}
+	d.msg = msg
+	d.r.Reset(msg)
+	d.mu.Unlock()
+	return len(b), nil
+}
+
+func (d *dnsConn) Read(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.r.Read(b)
+}
+
+func (d *dnsConn) Close() error                       { return nil }
+func (d *dnsConn) LocalAddr() net.Addr                { return dnsAddr{} }
+func (d *dnsConn) RemoteAddr() net.Addr               { return dnsAddr{} }
+func (d *dnsConn) SetDeadline(t time.Time) error      { return nil }
+func (d *dnsConn) SetReadDeadline(t time.Time) error  { return nil }
+func (d *dnsConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (p *dnsPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := p.Read(b)
+	return n, dnsAddr{}, err
+}
+
+func (p *dnsPacketConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return p.Write(b)
+}
+
+type dnsAddr struct{}
+
+func (dnsAddr) Network() string { return "dnscache" }
+func (dnsAddr) String() string  { return "dnscache" }
@@ -0,0 +1,178 @@
+package util
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dnsServer answers A queries for "*.test." with 192.0.2.1 (TTL 60);
+// names starting with "missing" get NXDOMAIN with an SOA (minimum 10).
+func dnsServer(t *testing.T, delay time.Duration) (string, *atomic.Int32) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	var n atomic.Int32
+	go func() {
+		b := make([]byte, 512)
+		for {
+			l, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			n.Add(1)
+			q := b[:l]
+			end, err := dnsSkipName(q, 12)
+			if err != nil {
+				continue
+			}
+			qname := strings.ToLower(string(q[13:end]))
+
+			r := append([]byte(nil), q[:2]...)
+			r = append(r, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0)
+			if strings.HasPrefix(qname, "missing") {
+				r[3] |= dnsRcodeNXDomain
+				r[7], r[9] = 0, 1
+			}
+			r = append(r, q[12:end+4]...)
+			if r[3]&0xf == 0 {
+				r = append(r, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 192, 0, 2, 1)
+			} else {
+				// test. SOA ns. host. serial refresh retry expire minimum
+				r = append(r, 4, 't', 'e', 's', 't', 0, 0, dnsTypeSOA, 0, 1, 0, 0, 0, 120, 0, 30,
+					2, 'n', 's', 0, 4, 'h', 'o', 's', 't', 0)
+				r = binary.BigEndian.AppendUint32(r, 1)
+				r = binary.BigEndian.AppendUint32(r, 2)
+				r = binary.BigEndian.AppendUint32(r, 3)
+				r = binary.BigEndian.AppendUint32(r, 4)
+				r = binary.BigEndian.AppendUint32(r, 10)
+			}
+			time.Sleep(delay)
+			pc.WriteTo(r, addr)
+		}
+	}()
+	return pc.LocalAddr().String(), &n
+}
+
+func TestDNSCache(t *testing.T) {
+	addr, queries := dnsServer(t, 0)
+	c := NewDNSCache(&DNSCacheOpt{Server: addr})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	r := c.Resolver()
+	ctx := context.Background()
+
+	want := netip.MustParseAddr("192.0.2.1")
+	for i := 0; i < 3; i++ {
+		ips, err := r.LookupNetIP(ctx, "ip4", "Host.test.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ips) != 1 || ips[0] != want {
+			t.Fatalf("got %v", ips)
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("want 1 upstream query, got %d", n)
+	}
+	if c.Len() != 1 {
+		t.Errorf("want 1 entry, got %d", c.Len())
+	}
+
+	// differently cased names share the entry
+	if _, err := r.LookupNetIP(ctx, "ip4", "HOST.TEST."); err != nil {
+		t.Fatal(err)
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("case: want 1 upstream query, got %d", n)
+	}
+
+	now = now.Add(61 * time.Second)
+	if _, err := r.LookupNetIP(ctx, "ip4", "host.test."); err != nil {
+		t.Fatal(err)
+	}
+	if n := queries.Load(); n != 2 {
+		t.Errorf("after expiry: want 2 upstream queries, got %d", n)
+	}
+
+	// negative answers are cached for min(SOA TTL, minimum) = 10s
+	for i := 0; i < 2; i++ {
+		_, err := r.LookupNetIP(ctx, "ip4", "missing.test.")
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			t.Fatalf("want not found, got %v", err)
+		}
+	}
+	if n := queries.Load(); n != 3 {
+		t.Errorf("negative: want 3 upstream queries, got %d", n)
+	}
+	now = now.Add(11 * time.Second)
+	r.LookupNetIP(ctx, "ip4", "missing.test.")
+	if n := queries.Load(); n != 4 {
+		t.Errorf("negative expiry: want 4 upstream queries, got %d", n)
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("purge left %d entries", c.Len())
+	}
+}
+
+func TestDNSCacheTTL(t *testing.T) {
+	addr, _ := dnsServer(t, 0)
+	c := NewDNSCache(&DNSCacheOpt{Server: addr})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	q := []byte{0x12, 0x34, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1, 'a', 4, 't', 'e', 's', 't', 0, 0, 1, 0, 1}
+	r, err := c.query(context.Background(), "udp", addr, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttlOff := len(r) - 10
+	if ttl := binary.BigEndian.Uint32(r[ttlOff:]); ttl != 60 {
+		t.Fatalf("want TTL 60, got %d", ttl)
+	}
+
+	now = now.Add(25 * time.Second)
+	q[0], q[1] = 0xab, 0xcd
+	r, err = c.query(context.Background(), "udp", addr, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r[0] != 0xab || r[1] != 0xcd {
+		t.Errorf("id not rewritten: %x", r[:2])
+	}
+	if ttl := binary.BigEndian.Uint32(r[ttlOff:]); ttl != 35 {
+		t.Errorf("want TTL 35, got %d", ttl)
+	}
+}
+
+func TestDNSCacheSingleflight(t *testing.T) {
+	addr, queries := dnsServer(t, 100*time.Millisecond)
+	r := NewDNSCache(&DNSCacheOpt{Server: addr}).Resolver()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.LookupNetIP(context.Background(), "ip4", "host.test."); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := queries.Load(); n != 1 {
+		t.Errorf("want 1 upstream query, got %d", n)
+	}
+}
@@ -0,0 +1,124 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// DrainListener wraps a listener and tracks the connections it accepts,
+// so that a server can stop accepting and wait for the open connections
+// to finish. Shutdown has the signature of a Shutdown hook:
+//
+//	s.OnShutdown("listener", ln.Shutdown)
+type DrainListener struct {
+	net.Listener
+
+	mu      sync.Mutex
+	conns   map[*drainConn]struct{}
+	drained chan struct{} // closed when conns empties during shutdown
+	closing bool
+}
+
+type drainConn struct {
+	net.Conn
+	l    *DrainListener
+	once sync.Once
+}
+
+// NewDrainListener wraps ln.
+func NewDrainListener(ln net.Listener) *DrainListener {
+	return &DrainListener{
+		Listener: ln,
+		conns:    make(map[*drainConn]struct{}),
+		drained:  make(chan struct{}),
+	}
+}
+
+// Accept waits for and returns the next tracked connection.
+func (l *DrainListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	dc := &drainConn{Conn: c, l: l}
+	l.mu.Lock()
+	if l.closing {
+		l.mu.Unlock()
+		c.Close()
+		return nil, net.ErrClosed
+	}
+	l.conns[dc] = struct{}{}
+	l.mu.Unlock()
+	return dc, nil
+}
+
+// Active returns the number of open connections.
+func (l *DrainListener) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.conns)
+}
+
+// Shutdown closes the listener and waits for the open connections to be
+// closed. If ctx is done first, the remaining connections are closed
+// forcibly and ctx.Err() is returned.
+func (l *DrainListener) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closing {
+		l.closing = true
+		if len(l.conns) == 0 {
+			close(l.drained)
+		}
+	}
+	l.mu.Unlock()
+
+	l.Listener.Close()
+
+	select {
+	case <-l.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	conns := make([]*drainConn, 0, len(l.conns))
+	for c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+	return ctx.Err()
+}
+
+// Close removes the connection from the listener's set.
+func (c *drainConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		l := c.l
+		l.mu.Lock()
+		delete(l.conns, c)
+		if l.closing && len(l.conns) == 0 {
+			close(l.drained)
+		}
+		l.mu.Unlock()
+	})
+	return err
+}
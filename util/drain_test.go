@@ -0,0 +1,90 @@
+package util
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDrainListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	l := NewDrainListener(ln)
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	cl, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer cl.Close()
+	sc := <-accepted
+	if l.Active() != 1 {
+		t.Fatalf("want 1 active connection, got %d", l.Active())
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- l.Shutdown(context.Background())
+	}()
+
+	// the accept loop ends, but shutdown waits for the open connection
+	<-accepted
+	select {
+	case <-done:
+		t.Fatalf("shutdown returned with a connection open")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sc.Close()
+	if err := <-done; err != nil {
+		t.Errorf("shutdown: %s", err)
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Errorf("listener still accepting")
+	}
+}
+
+func TestDrainListenerDeadline(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	l := NewDrainListener(ln)
+
+	go func() {
+		cl, _ := net.Dial("tcp", ln.Addr().String())
+		if cl != nil {
+			defer cl.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+	sc, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+
+	// the straggler was closed forcibly
+	if _, err := sc.Read(make([]byte, 1)); err == nil {
+		t.Errorf("connection still open after the deadline")
+	}
+	if l.Active() != 0 {
+		t.Errorf("want 0 active connections, got %d", l.Active())
+	}
+}
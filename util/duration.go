@@ -0,0 +1,182 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDuration is returned by ParseDuration for malformed input.
+var ErrInvalidDuration = errors.New("duration: invalid value")
+
+// Calendar-ish units understood by ParseDuration. Months and years are
+// fixed length approximations.
+const (
+	Day   = 24 * time.Hour
+	Week  = 7 * Day
+	Month = 30 * Day
+	Year  = 365 * Day
+)
+
+var durUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  Day,
+	"w":  Week,
+	"mo": Month,
+	"y":  Year,
+}
+
+// ParseDuration is like time.ParseDuration but also accepts the units
+// "d" (days), "w" (weeks), "mo" (30 day months) and "y" (365 day years),
+// e.g. "90d" or "1w2d12h". Spaces between the components are allowed.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidDuration
+	}
+
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		fallthrough
+	case '+':
+		s = s[1:]
+	}
+	if s == "0" {
+		return 0, nil
+	}
+
+	var total int64
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i <= 0 {
+			return 0, ErrInvalidDuration
+		}
+		num := s[:i]
+		s = s[i:]
+
+		j := strings.IndexFunc(s, func(r rune) bool {
+			return (r >= '0' && r <= '9') || r == '.' || r == ' '
+		})
+		if j < 0 {
+			j = len(s)
+		}
+		unit, ok := durUnits[s[:j]]
+		if !ok {
+			return 0, ErrInvalidDuration
+		}
+		s = s[j:]
+
+		n, err := durComponent(num, int64(unit))
+		if err != nil {
+			return 0, err
+		}
+		if total > math.MaxInt64-n {
+			return 0, strconv.ErrRange
+		}
+		total += n
+	}
+
+	d := time.Duration(total)
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// durComponent returns num units in nanoseconds. The integer part is
+// exact; a fraction is rounded to the nearest nanosecond.
+func durComponent(num string, unit int64) (int64, error) {
+	ip, fp, _ := strings.Cut(num, ".")
+	if strings.Contains(fp, ".") || (ip == "" && fp == "") {
+		return 0, ErrInvalidDuration
+	}
+
+	var n int64
+	if ip != "" {
+		v, err := strconv.ParseInt(ip, 10, 64)
+		if err != nil || v > math.MaxInt64/unit {
+			// ip is all digits, so the only possible error is range
+			return 0, strconv.ErrRange
+		}
+		n = v * unit
+	}
+
+	if fp != "" {
+		f, err := strconv.ParseFloat("0."+fp, 64)
+		if err != nil {
+			return 0, ErrInvalidDuration
+		}
+		fn := int64(math.Round(f * float64(unit)))
+		if n > math.MaxInt64-fn {
+			return 0, strconv.ErrRange
+		}
+		n += fn
+	}
+	return n, nil
+}
+
+// FormatDuration formats d compactly with the largest units that fit,
+// e.g. "12w6d" or "1d2h30m". Sub-second remainders are kept in
+// time.Duration notation. The result can be parsed with ParseDuration.
+func FormatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		// -MinInt64 overflows; lose a nanosecond instead
+		if d == math.MinInt64 {
+			d++
+		}
+		d = -d
+	}
+
+	for _, u := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"w", Week},
+		{"d", Day},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	} {
+		if d >= u.d {
+			b.WriteString(strconv.FormatInt(int64(d/u.d), 10))
+			b.WriteString(u.name)
+			d %= u.d
+		}
+	}
+
+	if d > 0 {
+		b.WriteString(d.String())
+	}
+	return b.String()
+}
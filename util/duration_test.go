@@ -0,0 +1,82 @@
+package util
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"0", 0},
+		{"90s", 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{"90d", 90 * Day},
+		{"2w", 14 * Day},
+		{"1w2d12h", 9*Day + 12*time.Hour},
+		{"1w 2d", 9 * Day},
+		{"6mo", 180 * Day},
+		{"1y", 365 * Day},
+		{"1.5d", 36 * time.Hour},
+		{"-3d", -3 * Day},
+		{"250ms", 250 * time.Millisecond},
+		{"10µs", 10 * time.Microsecond},
+		{"200d1ns", 200*Day + 1},
+		{"1.000000001s", time.Second + 1},
+		{".5h", 30 * time.Minute},
+		{"106751d23h47m16s854775807ns", math.MaxInt64},
+		{"-106751d23h47m16s854775807ns", -math.MaxInt64},
+	}
+
+	for _, tc := range tests {
+		d, err := ParseDuration(tc.in)
+		if err != nil {
+			t.Errorf("%q: %s", tc.in, err)
+			continue
+		}
+		if d != tc.want {
+			t.Errorf("%q: want %s, got %s", tc.in, tc.want, d)
+		}
+	}
+
+	for _, s := range []string{"", "d", "10", "10x", "1d-2h", "1..5d"} {
+		if _, err := ParseDuration(s); err != ErrInvalidDuration {
+			t.Errorf("%q: want ErrInvalidDuration, got %v", s, err)
+		}
+	}
+	for _, s := range []string{"1000y", "106751d23h47m16s854775808ns", "9223372036854775808ns", "15251w", "292y1.5y"} {
+		if _, err := ParseDuration(s); err != strconv.ErrRange {
+			t.Errorf("%q: want ErrRange, got %v", s, err)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{90 * Day, "12w6d"},
+		{26*time.Hour + 30*time.Minute, "1d2h30m"},
+		{time.Minute + 1500*time.Millisecond, "1m1s500ms"},
+		{-2 * Week, "-2w"},
+		{750 * time.Microsecond, "750µs"},
+		{28*Week + 4*Day + 1, "28w4d1ns"},
+		{math.MaxInt64, "15250w1d23h47m16s854.775807ms"},
+	}
+
+	for _, tc := range tests {
+		s := FormatDuration(tc.d)
+		if s != tc.want {
+			t.Errorf("%d: want %q, got %q", tc.d, tc.want, s)
+		}
+		if d, err := ParseDuration(s); err != nil || d != tc.d {
+			t.Errorf("round trip %q: got %s, %v", s, d, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidEncoding is returned when decoding malformed input.
+	ErrInvalidEncoding = errors.New("encoding: invalid input")
+
+	// ErrBadChecksum is returned by Base58CheckDecode when the
+	// checksum doesn't match.
+	ErrBadChecksum = errors.New("encoding: bad checksum")
+)
+
+// b58 is the Bitcoin base58 alphabet: no 0, O, I or l.
+const b58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var b58idx = func() [256]int8 {
+	var t [256]int8
+	for i := range t {
+		t[i] = -1
+	}
+	for i := 0; i < len(b58); i++ {
+		t[b58[i]] = int8(i)
+	}
+	return t
+}()
+
+// Base58Encode encodes b with the Bitcoin base58 alphabet. Leading zero
+// bytes are kept as leading '1's.
+func Base58Encode(b []byte) string {
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+
+	// log(256)/log(58) ~ 1.37
+	out := make([]byte, (len(b)-zeros)*138/100+1)
+	n := 0
+	for _, c := range b[zeros:] {
+		carry := int(c)
+		i := 0
+		for j := len(out) - 1; (carry != 0 || i < n) && j >= 0; j-- {
+			carry += 256 * int(out[j])
+			out[j] = byte(carry % 58)
+			carry /= 58
+			i++
+		}
+		n = i
+	}
+
+	r := make([]byte, zeros+n)
+	for i := 0; i < zeros; i++ {
+		r[i] = '1'
+	}
+	for i, c := range out[len(out)-n:] {
+		r[zeros+i] = b58[c]
+	}
+	return string(r)
+}
+
+// Base58Decode decodes a base58 string.
+func Base58Decode(s string) ([]byte, error) {
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+
+	// log(58)/log(256) ~ 0.733
+	out := make([]byte, (len(s)-zeros)*733/1000+1)
+	n := 0
+	for i := zeros; i < len(s); i++ {
+		v := b58idx[s[i]]
+		if v < 0 {
+			return nil, ErrInvalidEncoding
+		}
+
+		carry := int(v)
+		k := 0
+		for j := len(out) - 1; (carry != 0 || k < n) && j >= 0; j-- {
+			carry += 58 * int(out[j])
+			out[j] = byte(carry)
+			carry >>= 8
+			k++
+		}
+		n = k
+	}
+
+	r := make([]byte, zeros+n)
+	copy(r[zeros:], out[len(out)-n:])
+	return r, nil
+}
+
+// Base58CheckEncode appends a 4 byte double SHA-256 checksum to b and
+// encodes the result in base58, as Bitcoin addresses do. Any version
+// byte is part of b.
+func Base58CheckEncode(b []byte) string {
+	buf := make([]byte, len(b), len(b)+4)
+	copy(buf, b)
+	return Base58Encode(append(buf, b58check(b)...))
+}
+
+// Base58CheckDecode decodes s and verifies and strips its checksum.
+func Base58CheckDecode(s string) ([]byte, error) {
+	b, err := Base58Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 4 {
+		return nil, ErrInvalidEncoding
+	}
+
+	p, sum := b[:len(b)-4], b[len(b)-4:]
+	if !bytes.Equal(sum, b58check(p)) {
+		return nil, ErrBadChecksum
+	}
+	return p, nil
+}
+
+func b58check(b []byte) []byte {
+	h := sha256.Sum256(b)
+	h = sha256.Sum256(h[:])
+	return h[:4]
+}
+
+var b32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Base32Encode encodes b in lower case, unpadded base32 (RFC 4648
+// alphabet).
+func Base32Encode(b []byte) string {
+	return b32.EncodeToString(b)
+}
+
+// Base32Decode decodes unpadded base32 in either case; trailing padding
+// is tolerated.
+func Base32Decode(s string) ([]byte, error) {
+	b, err := b32.DecodeString(strings.ToLower(strings.TrimRight(s, "=")))
+	if err != nil {
+		return nil, ErrInvalidEncoding
+	}
+	return b, nil
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		hex, b58 string
+	}{
+		{"", ""},
+		{"00", "1"},
+		{"0000", "11"},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"636363", "aPEr"},
+		{"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"572e4794", "3EFU7m"},
+		{"10c8511e", "Rt5zm"},
+		{"00000000000000000000", "1111111111"},
+	}
+
+	for _, tc := range tests {
+		b, _ := hex.DecodeString(tc.hex)
+		if s := Base58Encode(b); s != tc.b58 {
+			t.Errorf("encode %s: want %s, got %s", tc.hex, tc.b58, s)
+		}
+		d, err := Base58Decode(tc.b58)
+		if err != nil || !bytes.Equal(d, b) {
+			t.Errorf("decode %s: want %s, got %x %v", tc.b58, tc.hex, d, err)
+		}
+	}
+
+	if _, err := Base58Decode("0OIl"); err != ErrInvalidEncoding {
+		t.Errorf("want ErrInvalidEncoding, got %v", err)
+	}
+}
+
+func TestBase58Check(t *testing.T) {
+	// a well known P2PKH address: version 0 + hash160
+	addr := "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	p, err := Base58CheckDecode(addr)
+	if err != nil {
+		t.Fatalf("decode: %s", err)
+	}
+	if len(p) != 21 || p[0] != 0 {
+		t.Fatalf("bad payload %x", p)
+	}
+	if s := Base58CheckEncode(p); s != addr {
+		t.Errorf("round trip: got %s", s)
+	}
+
+	if _, err := Base58CheckDecode("1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3"); err != ErrBadChecksum {
+		t.Errorf("want ErrBadChecksum, got %v", err)
+	}
+}
+
+func TestBase32(t *testing.T) {
+	b := []byte("foobar")
+	s := Base32Encode(b)
+	if s != "mzxw6ytboi" {
+		t.Errorf("want mzxw6ytboi, got %s", s)
+	}
+
+	for _, in := range []string{"mzxw6ytboi", "MZXW6YTBOI", "MZXW6YTBOI======"} {
+		d, err := Base32Decode(in)
+		if err != nil || !bytes.Equal(d, b) {
+			t.Errorf("%s: got %q %v", in, d, err)
+		}
+	}
+	if _, err := Base32Decode("mzxw1"); err != ErrInvalidEncoding {
+		t.Errorf("want ErrInvalidEncoding, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"sync"
+)
+
+var (
+	// ErrFrameTooLarge is returned for frames over the maximum size;
+	// the stream can't be resynchronized after it.
+	ErrFrameTooLarge = errors.New("frame: too large")
+
+	// ErrFrameCRC is returned when a frame fails its checksum.
+	ErrFrameCRC = errors.New("frame: checksum mismatch")
+)
+
+// FrameOpt configures a Framer. Both ends must use the same CRC setting.
+type FrameOpt struct {
+	// MaxFrame is the largest payload accepted or sent. Default 16 MiB.
+	MaxFrame int
+
+	// CRC appends a CRC-32C of the payload to every frame.
+	CRC bool
+}
+
+// Framer sends and receives length-prefixed messages over a stream: a 4
+// byte big endian length, the payload and, optionally, a CRC-32C. Writes
+// are safe for concurrent use; reads are not.
+type Framer struct {
+	opt FrameOpt
+	br  *bufio.Reader
+	w   io.Writer
+
+	rbuf []byte
+
+	wmu  sync.Mutex
+	wbuf []byte
+}
+
+// NewFramer returns a framer over rw.
+func NewFramer(rw io.ReadWriter, opt *FrameOpt) *Framer {
+	var o FrameOpt
+	if opt != nil {
+		o = *opt
+	}
+	if o.MaxFrame <= 0 {
+		o.MaxFrame = 16 << 20
+	}
+
+	return &Framer{
+		opt: o,
+		br:  bufio.NewReader(rw),
+		w:   rw,
+	}
+}
+
+// WriteFrame sends b as one frame.
+func (f *Framer) WriteFrame(b []byte) error {
+	// the length field is 32 bits, whatever MaxFrame says
+	if len(b) > f.opt.MaxFrame || uint64(len(b)) > math.MaxUint32 {
+		return ErrFrameTooLarge
+	}
+
+	f.wmu.Lock()
+	defer f.wmu.Unlock()
+
+	// one Write per frame so that concurrent writers never interleave
+	w := binary.BigEndian.AppendUint32(f.wbuf[:0], uint32(len(b)))
+	w = append(w, b...)
+	if f.opt.CRC {
+		w = binary.BigEndian.AppendUint32(w, crc32.Checksum(b, castagnoli))
+	}
+	f.wbuf = w
+
+	_, err := f.w.Write(w)
+
+	// don't pin a huge buffer after a one-off large frame
+	if cap(f.wbuf) > 64*1024 {
+		f.wbuf = nil
+	}
+	return err
+}
+
+// ReadFrame reads the next frame. The returned slice is reused by the
+// next call; copy it to keep it. At a clean end of stream it returns
+// io.EOF; a stream cut inside a frame gives io.ErrUnexpectedEOF.
+func (f *Framer) ReadFrame() ([]byte, error) {
+	b, err := f.ReadFrameInto(f.rbuf)
+	if err == nil && cap(b) <= 64*1024 {
+		f.rbuf = b[:0]
+	}
+	return b, err
+}
+
+// ReadFrameInto is like ReadFrame but reads into buf if it is large
+// enough, allocating otherwise.
+func (f *Framer) ReadFrameInto(buf []byte) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(f.br, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	// compare before converting: a length of 2^31 or more is negative
+	// as a 32-bit int
+	sz := binary.BigEndian.Uint32(hdr[:])
+	if uint64(sz) > uint64(f.opt.MaxFrame) {
+		return nil, ErrFrameTooLarge
+	}
+	n := int(sz)
+
+	if cap(buf) < n {
+		buf = make([]byte, n)
+	}
+	buf = buf[:n]
+	if _, err := io.ReadFull(f.br, buf); err != nil {
+		return nil, unexpected(err)
+	}
+
+	if f.opt.CRC {
+		if _, err := io.ReadFull(f.br, hdr[:]); err != nil {
+			return nil, unexpected(err)
+		}
+		if binary.BigEndian.Uint32(hdr[:]) != crc32.Checksum(buf, castagnoli) {
+			return nil, ErrFrameCRC
+		}
+	}
+	return buf, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestFramer(t *testing.T) {
+	for _, crc := range []bool{false, true} {
+		var buf bytes.Buffer
+		f := NewFramer(&buf, &FrameOpt{CRC: crc})
+
+		msgs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte("x"), 100000)}
+		for _, m := range msgs {
+			if err := f.WriteFrame(m); err != nil {
+				t.Fatalf("write: %s", err)
+			}
+		}
+
+		for i, m := range msgs {
+			b, err := f.ReadFrame()
+			if err != nil || !bytes.Equal(b, m) {
+				t.Fatalf("crc=%v frame %d: got %d bytes, %v", crc, i, len(b), err)
+			}
+		}
+		if _, err := f.ReadFrame(); err != io.EOF {
+			t.Errorf("want EOF, got %v", err)
+		}
+	}
+}
+
+func TestFramerErrors(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &FrameOpt{MaxFrame: 10, CRC: true})
+
+	if err := f.WriteFrame(make([]byte, 11)); err != ErrFrameTooLarge {
+		t.Errorf("want ErrFrameTooLarge on write, got %v", err)
+	}
+
+	f.WriteFrame([]byte("data"))
+	b := buf.Bytes()
+	b[5] ^= 1
+	if _, err := f.ReadFrame(); err != ErrFrameCRC {
+		t.Errorf("want ErrFrameCRC, got %v", err)
+	}
+
+	for _, hdr := range [][]byte{{0, 0, 1, 0}, {0x80, 0, 0, 0}, {0xff, 0xff, 0xff, 0xff}} {
+		buf.Reset()
+		buf.Write(hdr)
+		if _, err := f.ReadFrame(); err != ErrFrameTooLarge {
+			t.Errorf("%x: want ErrFrameTooLarge on read, got %v", hdr, err)
+		}
+	}
+
+	buf.Reset()
+	buf.Write([]byte{0, 0, 0, 5, 'a', 'b'})
+	if _, err := f.ReadFrame(); err != io.ErrUnexpectedEOF {
+		t.Errorf("want ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestFramerConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	fa := NewFramer(a, nil)
+	fb := NewFramer(b, nil)
+
+	// concurrent writers don't interleave frames
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				fa.WriteFrame(bytes.Repeat([]byte{byte('a' + i)}, 100))
+			}
+		}(i)
+	}
+
+	for n := 0; n < 200; n++ {
+		m, err := fb.ReadFrame()
+		if err != nil {
+			t.Fatalf("read: %s", err)
+		}
+		if len(m) != 100 || !bytes.Equal(m, bytes.Repeat(m[:1], 100)) {
+			t.Fatalf("interleaved frame: %q", m)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,148 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is the error returned for a Group function that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("group: panic: %v", e.Value)
+}
+
+// GroupOpt configures a Group.
+type GroupOpt struct {
+	// Limit is the maximum number of functions running at once; zero
+	// means no limit.
+	Limit int
+
+	// KeepGoing disables cancelling the group's context on the first
+	// error; Wait then returns all errors joined.
+	KeepGoing bool
+}
+
+// Group runs functions in goroutines and collects their errors, like
+// errgroup. By default the first error cancels the group's context and
+// is the one returned by Wait.
+type Group struct {
+	wg     sync.WaitGroup
+	sem    chan struct{}
+	cancel context.CancelFunc
+	ctx    context.Context
+	keep   bool
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup returns a group and a context derived from ctx that is
+// cancelled when a function fails (unless opt.KeepGoing is set) or Wait
+// returns.
+func NewGroup(ctx context.Context, opt *GroupOpt) (*Group, context.Context) {
+	var o GroupOpt
+	if opt != nil {
+		o = *opt
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{
+		cancel: cancel,
+		ctx:    ctx,
+		keep:   o.KeepGoing,
+	}
+	if o.Limit > 0 {
+		g.sem = make(chan struct{}, o.Limit)
+	}
+	return g, ctx
+}
+
+// Go runs fn in a new goroutine, blocking while the group is at its
+// concurrency limit. fn is passed the group's context.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(fn)
+}
+
+// TryGo runs fn only if the group is below its concurrency limit and
+// reports whether it was started.
+func (g *Group) TryGo(fn func(ctx context.Context) error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(fn)
+	return true
+}
+
+// Wait waits for all functions to return and returns the first error,
+// or with KeepGoing, all errors joined.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case len(g.errs) == 0:
+		return nil
+	case g.keep:
+		return errors.Join(g.errs...)
+	default:
+		return g.errs[0]
+	}
+}
+
+func (g *Group) start(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+
+		if err := g.run(fn); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+			if !g.keep {
+				g.cancel()
+			}
+		}
+	}()
+}
+
+func (g *Group) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn(g.ctx)
+}
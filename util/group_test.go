@@ -0,0 +1,103 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	g, ctx := NewGroup(context.Background(), &GroupOpt{Limit: 2})
+
+	var cur, max int32
+	for i := 0; i < 20; i++ {
+		g.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&cur, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&cur, -1)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		t.Fatalf("wait: %s", err)
+	}
+	if max > 2 {
+		t.Errorf("more than 2 functions ran at once: %d", max)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("context not cancelled after Wait")
+	}
+}
+
+func TestGroupFirstError(t *testing.T) {
+	g, _ := NewGroup(context.Background(), nil)
+
+	boom := errors.New("boom")
+	g.Go(func(ctx context.Context) error {
+		return boom
+	})
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := g.Wait(); err != boom {
+		t.Errorf("want boom, got %v", err)
+	}
+}
+
+func TestGroupKeepGoing(t *testing.T) {
+	g, ctx := NewGroup(context.Background(), &GroupOpt{KeepGoing: true})
+
+	e1, e2 := errors.New("one"), errors.New("two")
+	g.Go(func(context.Context) error { return e1 })
+	g.Go(func(context.Context) error { return e2 })
+	g.Go(func(context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return ctx.Err()
+	})
+
+	err := g.Wait()
+	if !errors.Is(err, e1) || !errors.Is(err, e2) {
+		t.Errorf("want both errors, got %v", err)
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	g, _ := NewGroup(context.Background(), &GroupOpt{Limit: 1})
+
+	g.Go(func(context.Context) error {
+		panic("oops")
+	})
+
+	var pe *PanicError
+	if err := g.Wait(); !errors.As(err, &pe) || pe.Value != "oops" {
+		t.Fatalf("want PanicError, got %v", err)
+	}
+	if len(pe.Stack) == 0 {
+		t.Errorf("no stack in PanicError")
+	}
+}
+
+func TestGroupTryGo(t *testing.T) {
+	g, _ := NewGroup(context.Background(), &GroupOpt{Limit: 1})
+
+	block := make(chan struct{})
+	if !g.TryGo(func(context.Context) error { <-block; return nil }) {
+		t.Fatalf("first TryGo failed")
+	}
+	if g.TryGo(func(context.Context) error { return nil }) {
+		t.Errorf("TryGo exceeded the limit")
+	}
+	close(block)
+	g.Wait()
+}
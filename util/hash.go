@@ -0,0 +1,203 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Non-cryptographic hashes. They are fast and well distributed but
+// trivially attacked; don't use them where an adversary picks the keys.
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// FNV1a64 returns the 64-bit FNV-1a hash of b; it matches hash/fnv's
+// New64a without the allocation.
+func FNV1a64(b []byte) uint64 {
+	h := uint64(fnvOffset64)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// FNV1a64String is FNV1a64 for strings.
+func FNV1a64String(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// HashString returns the xxHash64 (seed 0) of s.
+func HashString(s string) uint64 {
+	return XXH64([]byte(s), 0)
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXH64 returns the xxHash64 of b with the given seed.
+func XXH64(b []byte, seed uint64) uint64 {
+	n := len(b)
+	var h uint64
+
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = xxMerge(v1, v2, v3, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint64(n)
+	return xxFinish(h, b)
+}
+
+func xxRound(acc, in uint64) uint64 {
+	acc += in * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, v uint64) uint64 {
+	acc ^= xxRound(0, v)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func xxMerge(v1, v2, v3, v4 uint64) uint64 {
+	h := bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) +
+		bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+	h = xxMergeRound(h, v1)
+	h = xxMergeRound(h, v2)
+	h = xxMergeRound(h, v3)
+	return xxMergeRound(h, v4)
+}
+
+// xxFinish mixes in the tail (less than 32 bytes) and avalanches.
+func xxFinish(h uint64, b []byte) uint64 {
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+// xxh64 is the streaming form of XXH64.
+type xxh64 struct {
+	seed           uint64
+	v1, v2, v3, v4 uint64
+	total          uint64
+	buf            [32]byte
+	nbuf           int
+}
+
+// NewXXH64 returns a streaming xxHash64 with the given seed.
+func NewXXH64(seed uint64) hash.Hash64 {
+	x := &xxh64{seed: seed}
+	x.Reset()
+	return x
+}
+
+func (x *xxh64) Reset() {
+	x.v1 = x.seed + xxPrime1 + xxPrime2
+	x.v2 = x.seed + xxPrime2
+	x.v3 = x.seed
+	x.v4 = x.seed - xxPrime1
+	x.total = 0
+	x.nbuf = 0
+}
+
+func (x *xxh64) Size() int      { return 8 }
+func (x *xxh64) BlockSize() int { return 32 }
+
+func (x *xxh64) Write(b []byte) (int, error) {
+	n := len(b)
+	x.total += uint64(n)
+
+	if x.nbuf > 0 {
+		c := copy(x.buf[x.nbuf:], b)
+		x.nbuf += c
+		b = b[c:]
+		if x.nbuf < 32 {
+			return n, nil
+		}
+		x.block(x.buf[:])
+		x.nbuf = 0
+	}
+
+	for ; len(b) >= 32; b = b[32:] {
+		x.block(b)
+	}
+	x.nbuf = copy(x.buf[:], b)
+	return n, nil
+}
+
+func (x *xxh64) block(b []byte) {
+	x.v1 = xxRound(x.v1, binary.LittleEndian.Uint64(b[0:]))
+	x.v2 = xxRound(x.v2, binary.LittleEndian.Uint64(b[8:]))
+	x.v3 = xxRound(x.v3, binary.LittleEndian.Uint64(b[16:]))
+	x.v4 = xxRound(x.v4, binary.LittleEndian.Uint64(b[24:]))
+}
+
+func (x *xxh64) Sum64() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		h = xxMerge(x.v1, x.v2, x.v3, x.v4)
+	} else {
+		h = x.seed + xxPrime5
+	}
+	h += x.total
+	return xxFinish(h, x.buf[:x.nbuf])
+}
+
+func (x *xxh64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, x.Sum64())
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"hash/fnv"
+	"testing"
+)
+
+func TestXXH64(t *testing.T) {
+	tests := []struct {
+		in   string
+		seed uint64
+		want uint64
+	}{
+		{"", 0, 0xef46db3751d8e999},
+		{"a", 0, 0xd24ec4f1a98c6e5b},
+		{"abc", 0, 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0, 0xfbcea83c8a378bf1},
+	}
+
+	for _, tc := range tests {
+		if h := XXH64([]byte(tc.in), tc.seed); h != tc.want {
+			t.Errorf("%q: want %#x, got %#x", tc.in, tc.want, h)
+		}
+	}
+	if HashString("abc") != 0x44bc2cf5ad770999 {
+		t.Errorf("HashString mismatch")
+	}
+}
+
+func TestXXH64Streaming(t *testing.T) {
+	b := make([]byte, 300)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+
+	for n := 0; n <= len(b); n += 13 {
+		want := XXH64(b[:n], 42)
+		for _, chunk := range []int{1, 5, 31, 32, 33, 100} {
+			h := NewXXH64(42)
+			for p := b[:n]; len(p) > 0; {
+				c := min(chunk, len(p))
+				h.Write(p[:c])
+				p = p[c:]
+			}
+			if got := h.Sum64(); got != want {
+				t.Fatalf("len %d chunk %d: want %#x, got %#x", n, chunk, want, got)
+			}
+		}
+	}
+
+	h := NewXXH64(0)
+	h.Write([]byte("abc"))
+	h.Reset()
+	h.Write([]byte("a"))
+	if h.Sum64() != 0xd24ec4f1a98c6e5b {
+		t.Errorf("Reset didn't reset")
+	}
+	if s := h.Sum(nil); len(s) != 8 || s[0] != 0xd2 {
+		t.Errorf("bad Sum: %x", s)
+	}
+}
+
+func TestFNV1a(t *testing.T) {
+	for _, s := range []string{"", "a", "hello world", "node1#17"} {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		if FNV1a64([]byte(s)) != h.Sum64() || FNV1a64String(s) != h.Sum64() {
+			t.Errorf("%q: mismatch with hash/fnv", s)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Hexdump writes b to w in the canonical "hexdump -C" format: offset, 16
+// bytes in hex and the printable ASCII characters. Runs of identical
+// lines are collapsed into a single "*".
+func Hexdump(w io.Writer, b []byte) error {
+	return hexdump(w, b, true)
+}
+
+// HexdumpDiff is like Hexdump but never collapses repeated lines
+// ("hexdump -Cv"), so dumps of similar buffers line up under diff(1).
+func HexdumpDiff(w io.Writer, b []byte) error {
+	return hexdump(w, b, false)
+}
+
+func hexdump(w io.Writer, b []byte, squeeze bool) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	const hex = "0123456789abcdef"
+
+	bw := bufio.NewWriter(w)
+	line := make([]byte, 0, 80)
+	var prev []byte
+	var starred bool
+
+	for off := 0; off < len(b); off += 16 {
+		row := b[off:min(off+16, len(b))]
+		if squeeze && len(row) == 16 && bytes.Equal(row, prev) {
+			if !starred {
+				bw.WriteString("*\n")
+				starred = true
+			}
+			continue
+		}
+		prev, starred = row, false
+
+		line = fmt.Appendf(line[:0], "%08x  ", off)
+		for i := 0; i < 16; i++ {
+			if i < len(row) {
+				line = append(line, hex[row[i]>>4], hex[row[i]&0xf], ' ')
+			} else {
+				line = append(line, "   "...)
+			}
+			if i == 7 {
+				line = append(line, ' ')
+			}
+		}
+
+		line = append(line, " |"...)
+		for _, c := range row {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			line = append(line, c)
+		}
+		line = append(line, "|\n"...)
+		bw.Write(line)
+	}
+
+	fmt.Fprintf(bw, "%08x\n", len(b))
+	return bw.Flush()
+}
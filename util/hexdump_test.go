@@ -0,0 +1,45 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHexdump(t *testing.T) {
+	var out bytes.Buffer
+
+	Hexdump(&out, []byte("Hello, world!\n\x00\x01\x02"))
+	want := "00000000  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 01  |Hello, world!...|\n" +
+		"00000010  02                                                |.|\n" +
+		"00000011\n"
+	if out.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	Hexdump(&out, nil)
+	if out.Len() != 0 {
+		t.Errorf("empty input should produce no output, got %q", out.String())
+	}
+}
+
+func TestHexdumpSqueeze(t *testing.T) {
+	b := make([]byte, 64)
+	b[63] = 'x'
+
+	var out bytes.Buffer
+	Hexdump(&out, b)
+	want := "00000000  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00  |................|\n" +
+		"*\n" +
+		"00000030  00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 78  |...............x|\n" +
+		"00000040\n"
+	if out.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	HexdumpDiff(&out, b)
+	if n := bytes.Count(out.Bytes(), []byte("\n")); n != 5 {
+		t.Errorf("diff variant: want 5 lines, got %d:\n%s", n, out.String())
+	}
+}
@@ -0,0 +1,269 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier: a
+// 48-bit millisecond timestamp followed by 80 random bits.
+type ULID [16]byte
+
+// UUID is an RFC 9562 UUID; IDGen produces version 7 (time ordered)
+// UUIDs.
+type UUID [16]byte
+
+// ErrInvalidID is returned when parsing a malformed ULID or UUID.
+var ErrInvalidID = errors.New("id: invalid format")
+
+// IDGen generates ULIDs and UUIDv7s. IDs from the same generator are
+// strictly increasing: within a millisecond the random part of the
+// previous ID is incremented instead of drawing new random bits. It is
+// safe for concurrent use.
+type IDGen struct {
+	mu    sync.Mutex
+	ulid  ULID
+	uuid  UUID
+	now   func() time.Time
+	rand  func([]byte)
+	lastU uint64 // timestamps of the last IDs
+	lastV uint64
+}
+
+// NewIDGen returns a generator seeded from crypto/rand.
+func NewIDGen() *IDGen {
+	g := &IDGen{
+		now: time.Now,
+		rand: func(b []byte) {
+			// crypto/rand.Read doesn't fail on supported platforms
+			crand.Read(b)
+		},
+	}
+	return g
+}
+
+var defaultIDGen = NewIDGen()
+
+// NewULID returns a new ULID from the process wide generator.
+func NewULID() ULID {
+	return defaultIDGen.ULID()
+}
+
+// NewUUIDv7 returns a new version 7 UUID from the process wide generator.
+func NewUUIDv7() UUID {
+	return defaultIDGen.UUIDv7()
+}
+
+// ULID returns a new ULID.
+func (g *IDGen) ULID() ULID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.millis(g.lastU)
+	u := &g.ulid
+	if ms == g.lastU {
+		// bytes 6..15 form an 80-bit counter
+		if !incr(u[6:]) {
+			ms++
+			g.rand(u[6:])
+		}
+	} else {
+		g.rand(u[6:])
+	}
+
+	putMillis(u[:], ms)
+	g.lastU = ms
+	return *u
+}
+
+// UUIDv7 returns a new version 7 UUID.
+func (g *IDGen) UUIDv7() UUID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.millis(g.lastV)
+
+	// The 12 bits of rand_a and 62 bits of rand_b form one 74-bit
+	// counter; the version and variant bits sit between them.
+	const mask62 = 1<<62 - 1
+	hi := binary.BigEndian.Uint16(g.uuid[6:]) & 0x0fff
+	lo := binary.BigEndian.Uint64(g.uuid[8:]) & mask62
+	if ms == g.lastV {
+		if lo++; lo > mask62 {
+			lo = 0
+			hi++
+		}
+		if hi > 0x0fff {
+			ms++
+		}
+	}
+	if ms != g.lastV {
+		var b [10]byte
+		g.rand(b[:])
+		hi = binary.BigEndian.Uint16(b[:]) & 0x0fff
+		lo = binary.BigEndian.Uint64(b[2:]) & mask62
+	}
+
+	u := &g.uuid
+	putMillis(u[:], ms)
+	binary.BigEndian.PutUint16(u[6:], 0x7000|hi)
+	binary.BigEndian.PutUint64(u[8:], 1<<63|lo)
+
+	g.lastV = ms
+	return *u
+}
+
+// millis returns the current time in ms, never going backwards from last.
+func (g *IDGen) millis(last uint64) uint64 {
+	ms := uint64(g.now().UnixMilli())
+	if ms < last {
+		ms = last
+	}
+	return ms
+}
+
+func putMillis(b []byte, ms uint64) {
+	b[0] = byte(ms >> 40)
+	b[1] = byte(ms >> 32)
+	b[2] = byte(ms >> 24)
+	b[3] = byte(ms >> 16)
+	b[4] = byte(ms >> 8)
+	b[5] = byte(ms)
+}
+
+func getMillis(b []byte) time.Time {
+	ms := uint64(b[0])<<40 | uint64(b[1])<<32 | uint64(b[2])<<24 |
+		uint64(b[3])<<16 | uint64(b[4])<<8 | uint64(b[5])
+	return time.UnixMilli(int64(ms))
+}
+
+// incr increments the big-endian number in b and returns false if it
+// overflowed.
+func incr(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Time returns the timestamp embedded in the ULID.
+func (u ULID) Time() time.Time {
+	return getMillis(u[:])
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// String returns the canonical 26 character Crockford base32 encoding.
+func (u ULID) String() string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+
+	var b [26]byte
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(b[:])
+}
+
+// ParseULID parses the string form of a ULID. Lower case letters and the
+// Crockford aliases (I and L for 1, O for 0) are accepted.
+func ParseULID(s string) (ULID, error) {
+	var u ULID
+	if len(s) != 26 || s[0] > '7' {
+		return u, ErrInvalidID
+	}
+
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := crockfordValue(s[i])
+		if v < 0 {
+			return u, ErrInvalidID
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+func crockfordValue(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	switch c {
+	case 'I', 'L':
+		return 1
+	case 'O':
+		return 0
+	}
+	for i := 0; i < len(crockford); i++ {
+		if crockford[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Time returns the timestamp embedded in a version 7 UUID.
+func (u UUID) Time() time.Time {
+	return getMillis(u[:])
+}
+
+// Version returns the UUID version.
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// String returns the canonical xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form.
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+// ParseUUID parses the canonical string form of a UUID.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrInvalidID
+	}
+
+	h := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return u, ErrInvalidID
+	}
+	return u, nil
+}
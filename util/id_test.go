@@ -0,0 +1,135 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestULID(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+	g := NewIDGen()
+	g.now = func() time.Time { return now }
+
+	prev := g.ULID()
+	if !prev.Time().Equal(now) {
+		t.Errorf("want time %s, got %s", now, prev.Time())
+	}
+
+	for i := 0; i < 1000; i++ {
+		u := g.ULID()
+		if bytes.Compare(u[:], prev[:]) <= 0 || u.String() <= prev.String() {
+			t.Fatalf("%d: not increasing: %s <= %s", i, u, prev)
+		}
+		prev = u
+	}
+
+	// a clock going backwards doesn't break ordering
+	now = now.Add(-time.Second)
+	if u := g.ULID(); u.String() <= prev.String() {
+		t.Errorf("clock skew broke ordering")
+	}
+
+	s := prev.String()
+	if len(s) != 26 {
+		t.Fatalf("bad length: %s", s)
+	}
+	p, err := ParseULID(s)
+	if err != nil || p != prev {
+		t.Errorf("parse %s: got %s, %v", s, p, err)
+	}
+}
+
+func TestULIDOverflow(t *testing.T) {
+	now := time.UnixMilli(5000)
+	g := NewIDGen()
+	g.now = func() time.Time { return now }
+	g.rand = func(b []byte) {
+		for i := range b {
+			b[i] = 0xff
+		}
+	}
+
+	a := g.ULID()
+	b := g.ULID()
+	if b.Time().Sub(a.Time()) != time.Millisecond {
+		t.Errorf("overflow must advance the timestamp: %s -> %s", a.Time(), b.Time())
+	}
+}
+
+func TestParseULID(t *testing.T) {
+	u, err := ParseULID("01ARYZ6S41TSV4RRFFQ69G5FAV")
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if ms := u.Time().UnixMilli(); ms != 1469918176385 {
+		t.Errorf("want timestamp 1469918176385, got %d", ms)
+	}
+
+	l, err := ParseULID("01aryz6s41tsv4rrffq69g5fav")
+	if err != nil || l != u {
+		t.Errorf("lower case parse: got %s, %v", l, err)
+	}
+
+	for _, s := range []string{"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		if _, err := ParseULID(s); err != ErrInvalidID {
+			t.Errorf("%q: want ErrInvalidID, got %v", s, err)
+		}
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	g := NewIDGen()
+	g.now = func() time.Time { return now }
+
+	prev := g.UUIDv7()
+	for i := 0; i < 1000; i++ {
+		u := g.UUIDv7()
+		if u.Version() != 7 || u[8]>>6 != 2 {
+			t.Fatalf("bad version/variant: %s", u)
+		}
+		if u.String() <= prev.String() {
+			t.Fatalf("%d: not increasing: %s <= %s", i, u, prev)
+		}
+		if !u.Time().Equal(now) {
+			t.Fatalf("want time %s, got %s", now, u.Time())
+		}
+		prev = u
+	}
+
+	p, err := ParseUUID(prev.String())
+	if err != nil || p != prev {
+		t.Errorf("parse %s: got %s, %v", prev, p, err)
+	}
+	if _, err := ParseUUID("not-a-uuid"); err != ErrInvalidID {
+		t.Errorf("want ErrInvalidID, got %v", err)
+	}
+
+	if a, b := NewUUIDv7(), NewUUIDv7(); a == b {
+		t.Errorf("duplicate UUIDs: %s", a)
+	}
+	if a, b := NewULID(), NewULID(); a == b {
+		t.Errorf("duplicate ULIDs: %s", a)
+	}
+}
+
+func TestUUIDv7Overflow(t *testing.T) {
+	now := time.UnixMilli(5000)
+	g := NewIDGen()
+	g.now = func() time.Time { return now }
+	g.rand = func(b []byte) {
+		for i := range b {
+			b[i] = 0xff
+		}
+	}
+
+	a := g.UUIDv7()
+	b := g.UUIDv7()
+	if b.Time().Sub(a.Time()) != time.Millisecond {
+		t.Errorf("overflow must advance the timestamp: %s -> %s", a.Time(), b.Time())
+	}
+	if b.Version() != 7 || b.String() <= a.String() {
+		t.Errorf("bad uuid after overflow: %s -> %s", a, b)
+	}
+}
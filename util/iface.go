@@ -0,0 +1,172 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// ErrNoRoute is returned when no interface or source address can reach
+// the destination.
+var ErrNoRoute = errors.New("addr: no route")
+
+// Iface is a network interface with its addresses.
+type Iface struct {
+	Name         string
+	Index        int
+	MTU          int
+	Flags        net.Flags
+	HardwareAddr net.HardwareAddr
+
+	// Addrs are the interface's networks; IPv6 link-local addresses
+	// carry the interface as their zone.
+	Addrs []netip.Prefix
+}
+
+// Up reports whether the interface is administratively up.
+func (i Iface) Up() bool { return i.Flags&net.FlagUp != 0 }
+
+// Loopback reports whether the interface is a loopback interface.
+func (i Iface) Loopback() bool { return i.Flags&net.FlagLoopback != 0 }
+
+// Has reports whether a is one of the interface's addresses.
+func (i Iface) Has(a netip.Addr) bool {
+	a = a.Unmap().WithZone("")
+	for _, p := range i.Addrs {
+		if p.Addr().WithZone("") == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Interfaces returns the system's network interfaces with their
+// addresses.
+func Interfaces() ([]Iface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]Iface, 0, len(ifs))
+	for _, ifi := range ifs {
+		x := Iface{
+			Name:         ifi.Name,
+			Index:        ifi.Index,
+			MTU:          ifi.MTU,
+			Flags:        ifi.Flags,
+			HardwareAddr: ifi.HardwareAddr,
+		}
+
+		ia, err := ifi.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range ia {
+			p, err := netip.ParsePrefix(a.String())
+			if err != nil {
+				continue
+			}
+			addr := p.Addr().Unmap()
+			if addr.Is6() && addr.IsLinkLocalUnicast() {
+				addr = addr.WithZone(ifi.Name)
+			}
+			x.Addrs = append(x.Addrs, netip.PrefixFrom(addr, p.Bits()))
+		}
+		r = append(r, x)
+	}
+	return r, nil
+}
+
+// SourceAddr returns the local address the kernel would use to send to
+// dst. No packets are sent.
+func SourceAddr(dst netip.Addr) (netip.Addr, error) {
+	if !dst.IsValid() {
+		return netip.Addr{}, ErrInvalidAddr
+	}
+
+	// connecting a UDP socket only does the route lookup
+	c, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(dst, 9)))
+	if err != nil {
+		return netip.Addr{}, errors.Join(ErrNoRoute, err)
+	}
+	defer c.Close()
+
+	return c.LocalAddr().(*net.UDPAddr).AddrPort().Addr().Unmap(), nil
+}
+
+// IfaceFor returns the interface that traffic to dst leaves through.
+func IfaceFor(dst netip.Addr) (Iface, error) {
+	src, err := SourceAddr(dst)
+	if err != nil {
+		return Iface{}, err
+	}
+
+	ifs, err := Interfaces()
+	if err != nil {
+		return Iface{}, err
+	}
+	for _, i := range ifs {
+		if i.Has(src) {
+			return i, nil
+		}
+	}
+	return Iface{}, ErrNoRoute
+}
+
+// DefaultIface returns the interface of the default route, preferring
+// IPv4. The addresses probed are only used for the route lookup.
+func DefaultIface() (Iface, error) {
+	i, err := IfaceFor(netip.MustParseAddr("8.8.8.8"))
+	if err == nil {
+		return i, nil
+	}
+	return IfaceFor(netip.MustParseAddr("2001:4860:4860::8888"))
+}
+
+// nonPublic are special purpose ranges (RFC 6890 and friends) not
+// covered by the netip.Addr predicates.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),  // documentation
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"), // discard
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// IsPublic reports whether a is a globally routable unicast address,
+// i.e. not loopback, link-local, private (RFC 1918, RFC 4193), shared
+// (RFC 6598), documentation or otherwise reserved. Use
+// netip.Addr.IsPrivate to test for RFC 1918 space specifically.
+func IsPublic(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsGlobalUnicast() || a.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublic {
+		if p.Contains(a) {
+			return false
+		}
+	}
+	return true
+}
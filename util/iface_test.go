@@ -0,0 +1,84 @@
+package util
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"::ffff:8.8.4.4", true},
+		{"2606:4700::1111", true},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"127.0.0.1", false},
+		{"169.254.1.1", false},
+		{"192.0.2.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"2001:db8::1", false},
+		{"ff02::1", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsPublic(netip.MustParseAddr(tc.addr)); got != tc.want {
+			t.Errorf("%s: want %v, got %v", tc.addr, tc.want, got)
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	ifs, err := Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lo := netip.MustParseAddr("127.0.0.1")
+	var found bool
+	for _, i := range ifs {
+		if i.Has(lo) {
+			found = true
+			if !i.Loopback() || !i.Up() {
+				t.Errorf("%s: bad flags %s", i.Name, i.Flags)
+			}
+		}
+	}
+	if !found {
+		t.Skip("no interface with 127.0.0.1")
+	}
+
+	src, err := SourceAddr(lo)
+	if err != nil || src != lo {
+		t.Errorf("source for %s: got %s, %v", lo, src, err)
+	}
+	i, err := IfaceFor(lo)
+	if err != nil || !i.Loopback() {
+		t.Errorf("iface for %s: got %s, %v", lo, i.Name, err)
+	}
+
+	if _, err := SourceAddr(netip.Addr{}); err != ErrInvalidAddr {
+		t.Errorf("zero addr: want ErrInvalidAddr, got %v", err)
+	}
+}
+
+func TestDefaultIface(t *testing.T) {
+	i, err := DefaultIface()
+	if err != nil {
+		t.Skip("no default route:", err)
+	}
+	if !i.Up() || len(i.Addrs) == 0 {
+		t.Errorf("%s: down or without addresses", i.Name)
+	}
+}
@@ -0,0 +1,70 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build unix && !aix && !solaris
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+// Instance is the lock held by a process started with SingleInstance.
+type Instance struct {
+	ln net.Listener
+	pf *Pidfile
+}
+
+// SingleInstance ensures only one process per name runs at a time. On
+// Linux it binds the abstract unix socket "@name", which the kernel
+// releases when the process dies, so there is never a stale lock. On
+// other systems (or if the bind is refused, e.g. by a sandbox) it locks
+// name.pid in $XDG_RUNTIME_DIR or the temp dir with WritePidfile. If
+// another instance is running the error wraps ErrRunning.
+func SingleInstance(name string) (*Instance, error) {
+	name = filepath.Base(name)
+
+	if runtime.GOOS == "linux" {
+		ln, err := net.Listen("unix", "@"+name)
+		if err == nil {
+			return &Instance{ln: ln}, nil
+		}
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return nil, fmt.Errorf("%s: %w", name, ErrRunning)
+		}
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	pf, err := WritePidfile(filepath.Join(dir, name+".pid"))
+	if err != nil {
+		return nil, err
+	}
+	return &Instance{pf: pf}, nil
+}
+
+// Close releases the lock.
+func (i *Instance) Close() error {
+	if i.ln != nil {
+		return i.ln.Close()
+	}
+	return i.pf.Close()
+}
@@ -0,0 +1,34 @@
+//go:build unix && !aix && !solaris
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSingleInstance(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	name := fmt.Sprintf("golib-test-%d", os.Getpid())
+
+	a, err := SingleInstance(name)
+	if err != nil {
+		t.Fatalf("first instance: %s", err)
+	}
+
+	if _, err := SingleInstance(name); !errors.Is(err, ErrRunning) {
+		t.Fatalf("second instance: want ErrRunning, got %v", err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+
+	b, err := SingleInstance(name)
+	if err != nil {
+		t.Fatalf("instance after close: %s", err)
+	}
+	b.Close()
+}
@@ -0,0 +1,177 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Latency buckets are log-linear: each power of two is split into
+// 1<<latSubBits buckets, so a recorded value is off by at most 12.5%.
+// Values up to 2^latMaxExp ns (about 4.9 hours) are distinguished;
+// larger ones land in the last bucket.
+const (
+	latSubBits = 3
+	latSub     = 1 << latSubBits
+	latMaxExp  = 44
+	latBuckets = (latMaxExp - latSubBits + 1) * latSub
+)
+
+// LatencyHistogram records durations into fixed exponential buckets. It
+// uses constant memory and Record is lock-free: the counts are striped
+// over as many shards as there are Ps, rounded up to a power of two. Each
+// Record picks a shard at random with the runtime's per-thread source;
+// shards aren't pinned to a P, but concurrent recorders rarely collide.
+type LatencyHistogram struct {
+	shards []latShard
+	mask   uint32
+}
+
+type latShard struct {
+	counts [latBuckets]atomic.Uint64
+	sum    atomic.Int64
+	_      [cacheLine - 8]byte
+}
+
+// LatencyBucket is a non-empty bucket: Count values in [Lower, Upper).
+type LatencyBucket struct {
+	Lower, Upper time.Duration
+	Count        uint64
+}
+
+// NewLatencyHistogram returns an empty histogram sized for GOMAXPROCS.
+func NewLatencyHistogram() *LatencyHistogram {
+	n := 1 << bits.Len(uint(runtime.GOMAXPROCS(0)-1))
+	return &LatencyHistogram{
+		shards: make([]latShard, n),
+		mask:   uint32(n - 1),
+	}
+}
+
+// Record adds d to a randomly chosen shard. Negative durations count as
+// zero.
+func (h *LatencyHistogram) Record(d time.Duration) {
+	d = max(d, 0)
+	s := &h.shards[rand.Uint32()&h.mask]
+	s.counts[latIndex(uint64(d))].Add(1)
+	s.sum.Add(int64(d))
+}
+
+// Since records the time elapsed since t0; use as
+// "defer h.Since(time.Now())".
+func (h *LatencyHistogram) Since(t0 time.Time) {
+	h.Record(time.Since(t0))
+}
+
+// Count returns the number of recorded values.
+func (h *LatencyHistogram) Count() uint64 {
+	var n uint64
+	for i := range h.shards {
+		for j := range h.shards[i].counts {
+			n += h.shards[i].counts[j].Load()
+		}
+	}
+	return n
+}
+
+// Sum returns the total of the recorded values.
+func (h *LatencyHistogram) Sum() time.Duration {
+	var n int64
+	for i := range h.shards {
+		n += h.shards[i].sum.Load()
+	}
+	return time.Duration(n)
+}
+
+// Buckets returns the non-empty buckets in increasing order.
+func (h *LatencyHistogram) Buckets() []LatencyBucket {
+	counts := h.merge()
+	var r []LatencyBucket
+	for i, n := range counts {
+		if n > 0 {
+			lo, hi := latBounds(i)
+			r = append(r, LatencyBucket{time.Duration(lo), time.Duration(hi), n})
+		}
+	}
+	return r
+}
+
+// Quantile returns the q-quantile (0 <= q <= 1) of the recorded values,
+// as the midpoint of the bucket it falls in; 0 if nothing was recorded.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	return h.quantiles(q)[0]
+}
+
+// quantiles computes several quantiles from one merge of the shards.
+func (h *LatencyHistogram) quantiles(qs ...float64) []time.Duration {
+	counts := h.merge()
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+
+	r := make([]time.Duration, len(qs))
+	if total == 0 {
+		return r
+	}
+	for k, q := range qs {
+		rank := uint64(min(max(q, 0), 1)*float64(total-1)) + 1
+		var cum uint64
+		for i, n := range counts {
+			cum += n
+			if cum >= rank {
+				lo, hi := latBounds(i)
+				r[k] = time.Duration(lo + (hi-lo)/2)
+				break
+			}
+		}
+	}
+	return r
+}
+
+func (h *LatencyHistogram) merge() []uint64 {
+	counts := make([]uint64, latBuckets)
+	for i := range h.shards {
+		for j := range counts {
+			counts[j] += h.shards[i].counts[j].Load()
+		}
+	}
+	return counts
+}
+
+// latIndex returns the bucket for v nanoseconds.
+func latIndex(v uint64) int {
+	if v < latSub {
+		return int(v)
+	}
+	e := bits.Len64(v) - 1
+	if e >= latMaxExp {
+		return latBuckets - 1
+	}
+	return (e-latSubBits+1)*latSub + int(v>>(e-latSubBits))&(latSub-1)
+}
+
+// latBounds returns the range [lo, hi) of bucket i.
+func latBounds(i int) (lo, hi uint64) {
+	if i < latSub {
+		return uint64(i), uint64(i + 1)
+	}
+	e := i/latSub + latSubBits - 1
+	m := uint64(i%latSub + latSub)
+	return m << (e - latSubBits), (m + 1) << (e - latSubBits)
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	for _, v := range []uint64{0, 1, 7, 8, 9, 15, 16, 17, 1000, 123456789, 1<<latMaxExp - 1} {
+		i := latIndex(v)
+		lo, hi := latBounds(i)
+		if v < lo || v >= hi {
+			t.Errorf("%d: bucket %d is [%d, %d)", v, i, lo, hi)
+		}
+		if v >= latSub && float64(hi-lo)/float64(lo) > 0.125 {
+			t.Errorf("%d: bucket [%d, %d) too wide", v, lo, hi)
+		}
+	}
+	for i := 1; i < latBuckets; i++ {
+		_, prev := latBounds(i - 1)
+		if lo, _ := latBounds(i); lo != prev {
+			t.Fatalf("gap before bucket %d", i)
+		}
+	}
+	if i := latIndex(1 << 62); i != latBuckets-1 {
+		t.Errorf("huge value in bucket %d", i)
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewLatencyHistogram()
+	if h.Quantile(0.5) != 0 || h.Count() != 0 {
+		t.Fatal("empty histogram not empty")
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 1000; i++ {
+				h.Record(time.Duration(i) * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := h.Count(); n != 4000 {
+		t.Errorf("want 4000 values, got %d", n)
+	}
+	if s := h.Sum(); s != 4*500500*time.Microsecond {
+		t.Errorf("bad sum %s", s)
+	}
+
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Microsecond},
+		{0.5, 500 * time.Microsecond},
+		{0.99, 990 * time.Microsecond},
+		{1, time.Millisecond},
+	} {
+		got := h.Quantile(tc.q)
+		if d := float64(got-tc.want) / float64(tc.want); d < -0.07 || d > 0.07 {
+			t.Errorf("q%v: want ~%s, got %s", tc.q, tc.want, got)
+		}
+	}
+
+	var n uint64
+	b := h.Buckets()
+	for i, x := range b {
+		n += x.Count
+		if i > 0 && x.Lower < b[i-1].Upper {
+			t.Errorf("buckets out of order at %d", i)
+		}
+	}
+	if n != 4000 {
+		t.Errorf("buckets hold %d values", n)
+	}
+}
+
+func TestLatencyRegistry(t *testing.T) {
+	r := NewRegistry()
+	h := r.Latency("rpc_seconds")
+	if r.Latency("rpc_seconds") != h {
+		t.Fatal("want the same histogram")
+	}
+	h.Record(2 * time.Millisecond)
+
+	var out bytes.Buffer
+	r.WritePrometheus(&out)
+	s := out.String()
+	for _, want := range []string{
+		"# TYPE rpc_seconds summary\n",
+		"rpc_seconds{quantile=\"0.99\"} 0.00",
+		"rpc_seconds_sum 0.002\n",
+		"rpc_seconds_count 1\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q in:\n%s", want, s)
+		}
+	}
+
+	snap := r.Snapshot()["rpc_seconds"].(map[string]interface{})
+	if snap["count"] != uint64(1) {
+		t.Errorf("bad snapshot: %v", snap)
+	}
+}
+
+func BenchmarkLatencyRecord(b *testing.B) {
+	h := NewLatencyHistogram()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.Record(time.Millisecond)
+		}
+	})
+}
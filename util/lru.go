@@ -0,0 +1,255 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package util is a collection of small, general purpose data structures
+// and helpers.
+package util
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// LRUOpt configures an LRU cache.
+type LRUOpt[K comparable, V any] struct {
+	// Shards is the number of independently locked partitions of the
+	// cache; more shards reduce lock contention. Defaults to 16, and
+	// is reduced for small caches.
+	Shards int
+
+	// TTL is the default lifetime of an entry; zero means entries
+	// don't expire.
+	TTL time.Duration
+
+	// OnEvict, if set, is called for every entry that is evicted,
+	// expired or removed. It is called without any locks held.
+	OnEvict func(K, V)
+}
+
+// LRU is a concurrent, size bounded cache that evicts the least recently
+// used entries. Keys are spread across shards, each with its own lock and
+// LRU list, so the eviction order is only approximately global.
+type LRU[K comparable, V any] struct {
+	shards  []*lruShard[K, V]
+	seed    maphash.Seed
+	ttl     time.Duration
+	onEvict func(K, V)
+}
+
+type lruEntry[K comparable, V any] struct {
+	key        K
+	val        V
+	expire     time.Time
+	prev, next *lruEntry[K, V]
+}
+
+type lruShard[K comparable, V any] struct {
+	sync.Mutex
+	size int
+	m    map[K]*lruEntry[K, V]
+
+	// sentinel of a circular list; head.next is the most recently used
+	head lruEntry[K, V]
+}
+
+// lruMinShard is the smallest capacity a shard is given.
+const lruMinShard = 32
+
+// NewLRU returns a cache that holds about size entries; the capacity is
+// divided evenly among the shards and rounded up. Small caches get fewer
+// shards so that each holds at least lruMinShard entries.
+func NewLRU[K comparable, V any](size int, opt *LRUOpt[K, V]) *LRU[K, V] {
+	var o LRUOpt[K, V]
+	if opt != nil {
+		o = *opt
+	}
+	if size <= 0 {
+		size = 1
+	}
+	if o.Shards <= 0 {
+		o.Shards = 16
+	}
+	// small shards behave like a direct mapped cache and evict live
+	// entries on hash collisions long before the cache is full
+	o.Shards = min(o.Shards, max(1, size/lruMinShard))
+
+	c := &LRU[K, V]{
+		shards:  make([]*lruShard[K, V], o.Shards),
+		seed:    maphash.MakeSeed(),
+		ttl:     o.TTL,
+		onEvict: o.OnEvict,
+	}
+
+	per := (size + o.Shards - 1) / o.Shards
+	for i := range c.shards {
+		s := &lruShard[K, V]{
+			size: per,
+			m:    make(map[K]*lruEntry[K, V], per),
+		}
+		s.head.next = &s.head
+		s.head.prev = &s.head
+		c.shards[i] = s
+	}
+	return c
+}
+
+// Get returns the value stored under k and marks it as recently used.
+func (c *LRU[K, V]) Get(k K) (V, bool) {
+	var zero V
+
+	s := c.shard(k)
+	s.Lock()
+	e, ok := s.m[k]
+	if !ok {
+		s.Unlock()
+		return zero, false
+	}
+
+	if !e.expire.IsZero() && time.Now().After(e.expire) {
+		s.remove(e)
+		s.Unlock()
+		c.evicted(e)
+		return zero, false
+	}
+
+	s.moveToFront(e)
+	v := e.val
+	s.Unlock()
+	return v, true
+}
+
+// Add stores v under k with the default TTL.
+func (c *LRU[K, V]) Add(k K, v V) {
+	c.AddTTL(k, v, c.ttl)
+}
+
+// AddTTL stores v under k; the entry expires after ttl, or never if ttl
+// is zero.
+func (c *LRU[K, V]) AddTTL(k K, v V, ttl time.Duration) {
+	var exp time.Time
+	if ttl > 0 {
+		exp = time.Now().Add(ttl)
+	}
+
+	s := c.shard(k)
+	s.Lock()
+	if e, ok := s.m[k]; ok {
+		e.val = v
+		e.expire = exp
+		s.moveToFront(e)
+		s.Unlock()
+		return
+	}
+
+	e := &lruEntry[K, V]{key: k, val: v, expire: exp}
+	s.m[k] = e
+	s.pushFront(e)
+
+	var old *lruEntry[K, V]
+	if len(s.m) > s.size {
+		old = s.head.prev
+		s.remove(old)
+	}
+	s.Unlock()
+
+	if old != nil {
+		c.evicted(old)
+	}
+}
+
+// Remove deletes k from the cache and returns true if it was present.
+func (c *LRU[K, V]) Remove(k K) bool {
+	s := c.shard(k)
+	s.Lock()
+	e, ok := s.m[k]
+	if ok {
+		s.remove(e)
+	}
+	s.Unlock()
+
+	if ok {
+		c.evicted(e)
+	}
+	return ok
+}
+
+// Len returns the number of entries in the cache, including expired
+// entries that haven't been reclaimed yet.
+func (c *LRU[K, V]) Len() int {
+	var n int
+	for _, s := range c.shards {
+		s.Lock()
+		n += len(s.m)
+		s.Unlock()
+	}
+	return n
+}
+
+// Purge removes all entries from the cache.
+func (c *LRU[K, V]) Purge() {
+	for _, s := range c.shards {
+		s.Lock()
+		var v []*lruEntry[K, V]
+		for e := s.head.next; e != &s.head; e = e.next {
+			v = append(v, e)
+		}
+		s.m = make(map[K]*lruEntry[K, V], s.size)
+		s.head.next = &s.head
+		s.head.prev = &s.head
+		s.Unlock()
+
+		for _, e := range v {
+			c.evicted(e)
+		}
+	}
+}
+
+func (c *LRU[K, V]) shard(k K) *lruShard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := maphash.Comparable(c.seed, k)
+	return c.shards[h%uint64(len(c.shards))]
+}
+
+func (c *LRU[K, V]) evicted(e *lruEntry[K, V]) {
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.val)
+	}
+}
+
+func (s *lruShard[K, V]) pushFront(e *lruEntry[K, V]) {
+	e.prev = &s.head
+	e.next = s.head.next
+	e.next.prev = e
+	s.head.next = e
+}
+
+func (s *lruShard[K, V]) unlink(e *lruEntry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+}
+
+func (s *lruShard[K, V]) moveToFront(e *lruEntry[K, V]) {
+	if s.head.next != e {
+		s.unlink(e)
+		s.pushFront(e)
+	}
+}
+
+func (s *lruShard[K, V]) remove(e *lruEntry[K, V]) {
+	s.unlink(e)
+	delete(s.m, e.key)
+}
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	var evicted []int
+	c := NewLRU[int, string](3, &LRUOpt[int, string]{
+		Shards:  1,
+		OnEvict: func(k int, v string) { evicted = append(evicted, k) },
+	})
+
+	c.Add(1, "one")
+	c.Add(2, "two")
+	c.Add(3, "three")
+
+	// 1 becomes most recently used; 2 is now the oldest
+	if v, ok := c.Get(1); !ok || v != "one" {
+		t.Fatalf("get 1: got %q %v", v, ok)
+	}
+
+	c.Add(4, "four")
+	if _, ok := c.Get(2); ok {
+		t.Errorf("2 should have been evicted")
+	}
+	if len(evicted) != 1 || evicted[0] != 2 {
+		t.Errorf("want eviction of [2], got %v", evicted)
+	}
+
+	c.Add(3, "THREE")
+	if v, _ := c.Get(3); v != "THREE" {
+		t.Errorf("update: want THREE, got %q", v)
+	}
+	if c.Len() != 3 {
+		t.Errorf("want len 3, got %d", c.Len())
+	}
+
+	if !c.Remove(4) || c.Remove(4) {
+		t.Errorf("remove 4 should succeed exactly once")
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("want empty cache after purge, got %d", c.Len())
+	}
+	if len(evicted) != 4 {
+		t.Errorf("want 4 evictions, got %v", evicted)
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	c := NewLRU[string, int](10, &LRUOpt[string, int]{TTL: 20 * time.Millisecond})
+
+	c.Add("a", 1)
+	c.AddTTL("b", 2, 0)
+	c.AddTTL("c", 3, time.Hour)
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("a should have expired")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Errorf("b should never expire")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Errorf("c should not have expired yet")
+	}
+}
+
+func TestLRUConcurrent(t *testing.T) {
+	c := NewLRU[string, int](1000, nil)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				k := fmt.Sprintf("%d-%d", g, i%300)
+				c.Add(k, i)
+				c.Get(k)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > 1008 {
+		t.Errorf("cache exceeded its capacity: %d", n)
+	}
+}
+
+func BenchmarkLRUGet(b *testing.B) {
+	c := NewLRU[int, int](1024, nil)
+	for i := 0; i < 1024; i++ {
+		c.Add(i, i)
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			c.Get(i & 1023)
+			i++
+		}
+	})
+}
@@ -0,0 +1,162 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// ErrNoLeaf is returned when asking for the proof of a leaf that isn't in
+// the tree.
+var ErrNoLeaf = errors.New("merkle: leaf index out of range")
+
+// MerkleTree is a SHA-256 Merkle tree in the RFC 6962/9162 shape: leaves
+// and interior nodes are hashed with distinct prefixes (0x00 and 0x01)
+// so a leaf can't pose as a node, and an odd node at the end of a level
+// is promoted unchanged. Roots and proofs are compatible with Certificate
+// Transparency logs.
+type MerkleTree struct {
+	// levels[0] holds the leaf hashes, the last level the root
+	levels [][][32]byte
+}
+
+// NewMerkleTree builds a tree over leaves, e.g. manifest entries.
+func NewMerkleTree(leaves [][]byte) *MerkleTree {
+	h := make([][32]byte, len(leaves))
+	for i, b := range leaves {
+		h[i] = MerkleLeafHash(b)
+	}
+	return newMerkle(h)
+}
+
+// NewMerkleTreeReader builds a tree over the consecutive chunk sized
+// pieces of r; the last piece may be short.
+func NewMerkleTreeReader(r io.Reader, chunk int) (*MerkleTree, error) {
+	if chunk <= 0 {
+		chunk = 64 * 1024
+	}
+
+	var h [][32]byte
+	buf := make([]byte, chunk)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			h = append(h, MerkleLeafHash(buf[:n]))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newMerkle(h), nil
+}
+
+func newMerkle(leaves [][32]byte) *MerkleTree {
+	t := &MerkleTree{levels: [][][32]byte{leaves}}
+	for lv := leaves; len(lv) > 1; {
+		next := make([][32]byte, (len(lv)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(lv) {
+				next[i] = merkleNode(lv[2*i], lv[2*i+1])
+			} else {
+				next[i] = lv[2*i]
+			}
+		}
+		t.levels = append(t.levels, next)
+		lv = next
+	}
+	return t
+}
+
+// Len returns the number of leaves.
+func (t *MerkleTree) Len() int {
+	return len(t.levels[0])
+}
+
+// Root returns the root hash; an empty tree has the hash of no input.
+func (t *MerkleTree) Root() [32]byte {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return sha256.Sum256(nil)
+	}
+	return top[0]
+}
+
+// Proof returns the inclusion proof of leaf i: the sibling hashes from
+// the leaf up to the root.
+func (t *MerkleTree) Proof(i int) ([][32]byte, error) {
+	if i < 0 || i >= t.Len() {
+		return nil, ErrNoLeaf
+	}
+
+	var p [][32]byte
+	for _, lv := range t.levels[:len(t.levels)-1] {
+		if s := i ^ 1; s < len(lv) {
+			p = append(p, lv[s])
+		}
+		i >>= 1
+	}
+	return p, nil
+}
+
+// VerifyMerkleProof reports whether proof shows that leaf is the i-th of
+// n leaves in the tree with the given root (RFC 9162, 2.1.3.2).
+func VerifyMerkleProof(root [32]byte, leaf []byte, i, n int, proof [][32]byte) bool {
+	if i < 0 || i >= n {
+		return false
+	}
+
+	fn, sn := i, n-1
+	r := MerkleLeafHash(leaf)
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNode(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNode(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && r == root
+}
+
+// MerkleLeafHash returns the hash of a leaf.
+func MerkleLeafHash(b []byte) [32]byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(b)
+
+	var r [32]byte
+	h.Sum(r[:0])
+	return r
+}
+
+func merkleNode(l, r [32]byte) [32]byte {
+	var b [65]byte
+	b[0] = 1
+	copy(b[1:], l[:])
+	copy(b[33:], r[:])
+	return sha256.Sum256(b[:])
+}
@@ -0,0 +1,96 @@
+package util
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// The RFC 6962 test leaves used by Certificate Transparency
+var ctLeaves = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+
+func TestMerkleRoot(t *testing.T) {
+	var leaves [][]byte
+	for _, s := range ctLeaves {
+		b, _ := hex.DecodeString(s)
+		leaves = append(leaves, b)
+	}
+
+	want := map[int]string{
+		1: "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		2: "fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		3: "aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		8: "5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+	for n, root := range want {
+		tr := NewMerkleTree(leaves[:n])
+		r := tr.Root()
+		if hex.EncodeToString(r[:]) != root {
+			t.Errorf("%d leaves: want %s, got %x", n, root, r)
+		}
+	}
+
+	empty := NewMerkleTree(nil).Root()
+	if hex.EncodeToString(empty[:]) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("bad empty root %x", empty)
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, []byte(fmt.Sprintf("entry %d", i)))
+		}
+		tr := NewMerkleTree(leaves)
+		root := tr.Root()
+
+		for i := 0; i < n; i++ {
+			p, err := tr.Proof(i)
+			if err != nil {
+				t.Fatalf("proof %d/%d: %s", i, n, err)
+			}
+			if !VerifyMerkleProof(root, leaves[i], i, n, p) {
+				t.Fatalf("proof %d/%d doesn't verify", i, n)
+			}
+			if VerifyMerkleProof(root, []byte("forged"), i, n, p) {
+				t.Fatalf("forged leaf %d/%d verifies", i, n)
+			}
+			if n > 1 && VerifyMerkleProof(root, leaves[i], (i+1)%n, n, p) {
+				t.Fatalf("proof %d/%d verifies at the wrong index", i, n)
+			}
+		}
+	}
+
+	if _, err := NewMerkleTree(nil).Proof(0); err != ErrNoLeaf {
+		t.Errorf("want ErrNoLeaf, got %v", err)
+	}
+}
+
+func TestMerkleReader(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	tr, err := NewMerkleTreeReader(bytes.NewReader(data), 64)
+	if err != nil {
+		t.Fatalf("reader: %s", err)
+	}
+	if tr.Len() != 16 {
+		t.Fatalf("want 16 chunks, got %d", tr.Len())
+	}
+
+	var chunks [][]byte
+	for p := data; len(p) > 0; {
+		c := min(64, len(p))
+		chunks = append(chunks, p[:c])
+		p = p[c:]
+	}
+	if NewMerkleTree(chunks).Root() != tr.Root() {
+		t.Errorf("reader and slice trees differ")
+	}
+
+	p, _ := tr.Proof(15)
+	if !VerifyMerkleProof(tr.Root(), data[960:], 15, 16, p) {
+		t.Errorf("short last chunk doesn't verify")
+	}
+}
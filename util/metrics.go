@@ -0,0 +1,364 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add adds n to the counter.
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Add adds d (which may be negative) to the gauge.
+func (g *Gauge) Add(d float64) {
+	addFloat(&g.bits, d)
+}
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// DefBuckets are the default histogram buckets, in seconds, suited to
+// network and disk latencies.
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into buckets with fixed upper bounds.
+type Histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // one more than bounds, for +Inf
+	sum    atomic.Uint64
+	n      atomic.Uint64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefBuckets
+	}
+	b := append([]float64(nil), bounds...)
+	sort.Float64s(b)
+	return &Histogram{
+		bounds: b,
+		counts: make([]atomic.Uint64, len(b)+1),
+	}
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i].Add(1)
+	h.n.Add(1)
+	addFloat(&h.sum, v)
+}
+
+// ObserveDuration records d in seconds.
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// Since records the time elapsed since t0; use as
+// "defer h.Since(time.Now())".
+func (h *Histogram) Since(t0 time.Time) {
+	h.ObserveDuration(time.Since(t0))
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.n.Load()
+}
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(h.sum.Load())
+}
+
+func addFloat(p *atomic.Uint64, d float64) {
+	for {
+		o := p.Load()
+		n := math.Float64bits(math.Float64frombits(o) + d)
+		if p.CompareAndSwap(o, n) {
+			return
+		}
+	}
+}
+
+// Registry is a named collection of metrics that can be exported in the
+// Prometheus text format, via expvar or to a log. It is safe for
+// concurrent use.
+type Registry struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{m: make(map[string]interface{})}
+}
+
+// Counter returns the counter called name, creating it if needed. It
+// panics if name is invalid or already used by another kind of metric.
+func (r *Registry) Counter(name string) *Counter {
+	return getMetric(r, name, func() *Counter { return &Counter{} })
+}
+
+// Gauge returns the gauge called name, creating it if needed.
+func (r *Registry) Gauge(name string) *Gauge {
+	return getMetric(r, name, func() *Gauge { return &Gauge{} })
+}
+
+// Histogram returns the histogram called name, creating it with the given
+// bucket bounds (DefBuckets if empty) if needed.
+func (r *Registry) Histogram(name string, bounds ...float64) *Histogram {
+	return getMetric(r, name, func() *Histogram { return newHistogram(bounds) })
+}
+
+// Latency returns the latency histogram called name, creating it if
+// needed. It is exported as a summary with the quantiles in
+// latencyQuantiles, in seconds.
+func (r *Registry) Latency(name string) *LatencyHistogram {
+	return getMetric(r, name, NewLatencyHistogram)
+}
+
+var latencyQuantiles = []float64{0.5, 0.9, 0.99, 0.999}
+
+func getMetric[T any](r *Registry, name string, mk func() *T) *T {
+	if !validMetricName(name) {
+		panic(fmt.Sprintf("metrics: invalid name %q", name))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if v, ok := r.m[name]; ok {
+		m, ok := v.(*T)
+		if !ok {
+			panic(fmt.Sprintf("metrics: %s is a %T", name, v))
+		}
+		return m
+	}
+
+	m := mk()
+	r.m[name] = m
+	return m
+}
+
+func validMetricName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_' || c == ':':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// sorted returns the metric names in order; the metrics themselves are
+// read without the registry lock.
+func (r *Registry) sorted() ([]string, map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.m))
+	m := make(map[string]interface{}, len(r.m))
+	for k, v := range r.m {
+		names = append(names, k)
+		m[k] = v
+	}
+	sort.Strings(names)
+	return names, m
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition
+// format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	names, m := r.sorted()
+	for _, name := range names {
+		switch v := m[name].(type) {
+		case *Counter:
+			fmt.Fprintf(bw, "# TYPE %s counter\n%s %d\n", name, name, v.Value())
+		case *Gauge:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s %s\n", name, name, promFloat(v.Value()))
+		case *Histogram:
+			fmt.Fprintf(bw, "# TYPE %s histogram\n", name)
+			var cum uint64
+			for i, b := range v.bounds {
+				cum += v.counts[i].Load()
+				fmt.Fprintf(bw, "%s_bucket{le=\"%s\"} %d\n", name, promFloat(b), cum)
+			}
+			cum += v.counts[len(v.bounds)].Load()
+			fmt.Fprintf(bw, "%s_bucket{le=\"+Inf\"} %d\n", name, cum)
+			fmt.Fprintf(bw, "%s_sum %s\n%s_count %d\n", name, promFloat(v.Sum()), name, cum)
+		case *LatencyHistogram:
+			fmt.Fprintf(bw, "# TYPE %s summary\n", name)
+			for i, d := range v.quantiles(latencyQuantiles...) {
+				fmt.Fprintf(bw, "%s{quantile=\"%s\"} %s\n", name, promFloat(latencyQuantiles[i]), promFloat(d.Seconds()))
+			}
+			fmt.Fprintf(bw, "%s_sum %s\n%s_count %d\n", name, promFloat(v.Sum().Seconds()), name, v.Count())
+		}
+	}
+	return bw.Flush()
+}
+
+func promFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Handler returns an HTTP handler serving the metrics in the Prometheus
+// text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WritePrometheus(w)
+	})
+}
+
+// Snapshot returns the current values: counters and gauges as numbers,
+// histograms as a map with count, sum and per-bucket counts, latency
+// histograms with count, sum and quantiles.
+func (r *Registry) Snapshot() map[string]interface{} {
+	names, m := r.sorted()
+	s := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		switch v := m[name].(type) {
+		case *Counter:
+			s[name] = v.Value()
+		case *Gauge:
+			s[name] = v.Value()
+		case *Histogram:
+			b := make(map[string]uint64, len(v.bounds)+1)
+			for i, x := range v.bounds {
+				b[promFloat(x)] = v.counts[i].Load()
+			}
+			b["+Inf"] = v.counts[len(v.bounds)].Load()
+			s[name] = map[string]interface{}{
+				"count":   v.Count(),
+				"sum":     v.Sum(),
+				"buckets": b,
+			}
+		case *LatencyHistogram:
+			q := make(map[string]float64, len(latencyQuantiles))
+			for i, d := range v.quantiles(latencyQuantiles...) {
+				q[promFloat(latencyQuantiles[i])] = d.Seconds()
+			}
+			s[name] = map[string]interface{}{
+				"count":     v.Count(),
+				"sum":       v.Sum().Seconds(),
+				"quantiles": q,
+			}
+		}
+	}
+	return s
+}
+
+// PublishExpvar exposes the registry's snapshot as the expvar variable
+// name (served on /debug/vars). Like expvar.Publish, it panics if name
+// is already in use.
+func (r *Registry) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return r.Snapshot()
+	}))
+}
+
+// LogEvery calls logf with a one line summary of each metric every
+// interval, until the returned stop function is called. log.Printf is a
+// suitable logf.
+func (r *Registry) LogEvery(interval time.Duration, logf func(format string, args ...interface{})) (stop func()) {
+	t := time.NewTicker(interval)
+	quit := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		for {
+			select {
+			case <-t.C:
+				r.log(logf)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			t.Stop()
+			close(quit)
+		})
+	}
+}
+
+func (r *Registry) log(logf func(format string, args ...interface{})) {
+	names, m := r.sorted()
+	for _, name := range names {
+		switch v := m[name].(type) {
+		case *Counter:
+			logf("metric %s=%d", name, v.Value())
+		case *Gauge:
+			logf("metric %s=%s", name, promFloat(v.Value()))
+		case *Histogram:
+			n := v.Count()
+			avg := 0.0
+			if n > 0 {
+				avg = v.Sum() / float64(n)
+			}
+			logf("metric %s count=%d sum=%s avg=%s", name, n, promFloat(v.Sum()), promFloat(avg))
+		case *LatencyHistogram:
+			q := v.quantiles(0.5, 0.99, 1)
+			logf("metric %s count=%d p50=%s p99=%s max=%s", name, v.Count(), q[0], q[1], q[2])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.Counter("requests_total")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if r.Counter("requests_total") != c || c.Value() != 1000 {
+		t.Fatalf("want the same counter with 1000, got %d", c.Value())
+	}
+
+	g := r.Gauge("queue_depth")
+	g.Set(5)
+	g.Add(-1.5)
+
+	h := r.Histogram("latency_seconds", 0.1, 0.5, 1)
+	for _, v := range []float64{0.05, 0.2, 0.3, 2} {
+		h.Observe(v)
+	}
+
+	var out bytes.Buffer
+	r.WritePrometheus(&out)
+	want := `# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="0.5"} 3
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 2.55
+latency_seconds_count 4
+# TYPE queue_depth gauge
+queue_depth 3.5
+# TYPE requests_total counter
+requests_total 1000
+`
+	if out.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, out.String())
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Body.String() != want {
+		t.Errorf("handler output differs")
+	}
+
+	snap := r.Snapshot()
+	if snap["requests_total"] != uint64(1000) || snap["queue_depth"] != 3.5 {
+		t.Errorf("bad snapshot: %v", snap)
+	}
+}
+
+func TestMetricsMisuse(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("x")
+
+	for _, fn := range []func(){
+		func() { r.Gauge("x") },
+		func() { r.Counter("1bad") },
+		func() { r.Counter("bad-name") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic")
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestMetricsLog(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("hits").Add(3)
+
+	var mu sync.Mutex
+	var lines []string
+	stop := r.LogEvery(5*time.Millisecond, func(f string, args ...interface{}) {
+		mu.Lock()
+		lines = append(lines, fmt.Sprintf(f, args...))
+		mu.Unlock()
+	})
+	time.Sleep(30 * time.Millisecond)
+	stop()
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) == 0 || !strings.Contains(lines[0], "hits=3") {
+		t.Errorf("want hits=3 logged, got %v", lines)
+	}
+}
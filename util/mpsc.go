@@ -0,0 +1,106 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"sync/atomic"
+)
+
+// MPSC is an unbounded multi-producer single-consumer queue (Dmitry
+// Vyukov's linked list design). Linking an element in is one atomic swap
+// and a store, with no preallocated capacity; only one goroutine may call
+// Pop. Push is not lock-free as a whole: it also signals Ready with a
+// non-blocking send on a one-slot channel, which takes the channel's
+// lock unless a wake-up is already pending.
+//
+// A consumer that wants to sleep while the queue is empty can wait on
+// Ready:
+//
+//	for {
+//		for v, ok := q.Pop(); ok; v, ok = q.Pop() {
+//			handle(v)
+//		}
+//		<-q.Ready()
+//	}
+type MPSC[T any] struct {
+	// head is the most recently pushed node; producers swap it
+	head atomic.Pointer[mpscNode[T]]
+	_    [cacheLine - 8]byte
+
+	// tail is the last consumed node (initially the stub); its next is
+	// the oldest element
+	tail  *mpscNode[T]
+	stub  mpscNode[T]
+	ready chan struct{}
+}
+
+type mpscNode[T any] struct {
+	next atomic.Pointer[mpscNode[T]]
+	v    T
+}
+
+// NewMPSC returns an empty queue.
+func NewMPSC[T any]() *MPSC[T] {
+	q := &MPSC[T]{
+		ready: make(chan struct{}, 1),
+	}
+	q.tail = &q.stub
+	q.head.Store(&q.stub)
+	return q
+}
+
+// Push appends v to the queue. It is safe to call from any goroutine.
+func (q *MPSC[T]) Push(v T) {
+	n := &mpscNode[T]{v: v}
+	prev := q.head.Swap(n)
+
+	// Between the swap and this store the queue is briefly disconnected;
+	// Pop sees it as empty until the link is made.
+	prev.next.Store(n)
+
+	// never blocks; a full slot means the consumer is already due to wake
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Pop removes and returns the oldest element, or returns false if the
+// queue is empty. It must only be called by the consumer.
+func (q *MPSC[T]) Pop() (T, bool) {
+	var zero T
+
+	next := q.tail.next.Load()
+	if next == nil {
+		return zero, false
+	}
+
+	// next becomes the new stub; clear its value so the queue doesn't
+	// pin it
+	v := next.v
+	next.v = zero
+	q.tail = next
+	return v, true
+}
+
+// Empty reports whether the queue looks empty to the consumer.
+func (q *MPSC[T]) Empty() bool {
+	return q.tail.next.Load() == nil
+}
+
+// Ready returns a channel that receives a value after a Push; it is
+// buffered, so a wake-up is never lost but may be spurious.
+func (q *MPSC[T]) Ready() <-chan struct{} {
+	return q.ready
+}
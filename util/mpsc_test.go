@@ -0,0 +1,62 @@
+package util
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMPSC(t *testing.T) {
+	q := NewMPSC[int]()
+	if _, ok := q.Pop(); ok || !q.Empty() {
+		t.Fatalf("new queue not empty")
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Push(i)
+	}
+	for i := 0; i < 10; i++ {
+		v, ok := q.Pop()
+		if !ok || v != i {
+			t.Fatalf("pop %d: got %d, %v", i, v, ok)
+		}
+	}
+	if _, ok := q.Pop(); ok {
+		t.Fatalf("pop from an empty queue succeeded")
+	}
+}
+
+func TestMPSCConcurrent(t *testing.T) {
+	const producers, n = 8, 20000
+	q := NewMPSC[[2]int]()
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				q.Push([2]int{p, i})
+			}
+		}(p)
+	}
+
+	// each producer's elements arrive in order
+	next := make([]int, producers)
+	for got := 0; got < producers*n; {
+		v, ok := q.Pop()
+		if !ok {
+			<-q.Ready()
+			continue
+		}
+		if v[1] != next[v[0]] {
+			t.Fatalf("producer %d: want %d, got %d", v[0], next[v[0]], v[1])
+		}
+		next[v[0]]++
+		got++
+	}
+	wg.Wait()
+
+	if !q.Empty() {
+		t.Errorf("queue not empty after draining")
+	}
+}
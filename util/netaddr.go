@@ -0,0 +1,198 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAddr is wrapped by the errors returned from the address
+// parsers below.
+var ErrInvalidAddr = errors.New("addr: invalid address")
+
+// ListenSpec is a parsed listen address, suitable for net.Listen.
+type ListenSpec struct {
+	Network string // tcp, tcp4, tcp6, udp, udp4, udp6 or unix
+	Address string // host:port or a socket path
+}
+
+func (l ListenSpec) String() string {
+	return l.Network + ":" + l.Address
+}
+
+// ParseListen parses a listen spec of the form
+//
+//	host:port, [v6addr]:port, :port   - tcp
+//	tcp4:host:port, udp::port etc.    - explicit network
+//	unix:/path/to/sock, unix:@name    - unix domain (@ is abstract)
+//
+// The port may be a number or a service name.
+func ParseListen(s string) (ListenSpec, error) {
+	l := ListenSpec{Network: "tcp"}
+
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		switch n := s[:i]; n {
+		case "unix":
+			if len(s) == i+1 {
+				return l, fmt.Errorf("listen %q: %w", s, ErrInvalidAddr)
+			}
+			return ListenSpec{Network: n, Address: s[i+1:]}, nil
+
+		case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+			l.Network = n
+			s = s[i+1:]
+		}
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return l, fmt.Errorf("listen %q: %w", s, ErrInvalidAddr)
+	}
+	if _, err := net.LookupPort(strings.TrimRight(l.Network, "46"), port); err != nil {
+		return l, fmt.Errorf("listen %q: bad port: %w", s, ErrInvalidAddr)
+	}
+
+	l.Address = net.JoinHostPort(host, port)
+	return l, nil
+}
+
+// ResolveListen is like ParseListen but a host that names a network
+// interface ("eth0:8080") is expanded to one spec per address on that
+// interface; with tcp4/tcp6 only the matching family is kept.
+func ResolveListen(s string) ([]ListenSpec, error) {
+	l, err := ParseListen(s)
+	if err != nil {
+		return nil, err
+	}
+	if l.Network == "unix" {
+		return []ListenSpec{l}, nil
+	}
+
+	host, port, _ := net.SplitHostPort(l.Address)
+	ifi, err := net.InterfaceByName(host)
+	if host == "" || err != nil {
+		return []ListenSpec{l}, nil
+	}
+
+	addrs, err := IfaceAddrs(ifi.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []ListenSpec
+	for _, a := range addrs {
+		switch {
+		case strings.HasSuffix(l.Network, "4") && !a.Is4():
+			continue
+		case strings.HasSuffix(l.Network, "6") && a.Is4():
+			continue
+		}
+		r = append(r, ListenSpec{
+			Network: l.Network,
+			Address: net.JoinHostPort(a.String(), port),
+		})
+	}
+	if len(r) == 0 {
+		return nil, fmt.Errorf("listen %q: no usable address on %s: %w", s, host, ErrInvalidAddr)
+	}
+	return r, nil
+}
+
+// IfaceAddrs returns the unicast addresses of the named interface.
+// IPv6 link-local addresses carry the interface as their zone.
+func IfaceAddrs(name string) ([]netip.Addr, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ia, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var r []netip.Addr
+	for _, x := range ia {
+		pfx, err := netip.ParsePrefix(x.String())
+		if err != nil {
+			continue
+		}
+		a := pfx.Addr().Unmap()
+		if a.Is6() && a.IsLinkLocalUnicast() {
+			a = a.WithZone(ifi.Name)
+		}
+		r = append(r, a)
+	}
+	return r, nil
+}
+
+// ParsePrefix parses a CIDR network. A bare address is taken as a single
+// host (/32 or /128). Unlike netip.ParsePrefix, a prefix with host bits
+// set ("10.1.2.3/8") is rejected as it is almost always a typo.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("cidr %q: %w", s, ErrInvalidAddr)
+		}
+		a = a.Unmap()
+		return netip.PrefixFrom(a, a.BitLen()), nil
+	}
+
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return p, fmt.Errorf("cidr %q: %w", s, ErrInvalidAddr)
+	}
+	if p.Masked() != p {
+		return p, fmt.Errorf("cidr %q: host bits set, did you mean %s: %w", s, p.Masked(), ErrInvalidAddr)
+	}
+	return p, nil
+}
+
+// ParsePrefixes parses a comma or white space separated list of networks
+// with ParsePrefix.
+func ParsePrefixes(s string) ([]netip.Prefix, error) {
+	f := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+
+	r := make([]netip.Prefix, 0, len(f))
+	for _, x := range f {
+		p, err := ParsePrefix(x)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, p)
+	}
+	return r, nil
+}
+
+// SplitPort splits host:port and converts the port to a number.
+func SplitPort(s string) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return "", 0, fmt.Errorf("%q: %w", s, ErrInvalidAddr)
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("%q: bad port: %w", s, ErrInvalidAddr)
+	}
+	return host, uint16(n), nil
+}
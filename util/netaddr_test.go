@@ -0,0 +1,107 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseListen(t *testing.T) {
+	tests := []struct {
+		in, net, addr string
+	}{
+		{"127.0.0.1:80", "tcp", "127.0.0.1:80"},
+		{":8080", "tcp", ":8080"},
+		{"[::1]:443", "tcp", "[::1]:443"},
+		{"tcp6:[::]:22", "tcp6", "[::]:22"},
+		{"udp::53", "udp", ":53"},
+		{"unix:/run/app.sock", "unix", "/run/app.sock"},
+		{"unix:@abstract", "unix", "@abstract"},
+		{"localhost:http", "tcp", "localhost:http"},
+	}
+
+	for _, tc := range tests {
+		l, err := ParseListen(tc.in)
+		if err != nil {
+			t.Errorf("%q: %s", tc.in, err)
+			continue
+		}
+		if l.Network != tc.net || l.Address != tc.addr {
+			t.Errorf("%q: want %s %s, got %s %s", tc.in, tc.net, tc.addr, l.Network, l.Address)
+		}
+	}
+
+	for _, s := range []string{"", "localhost", "::1:80", "unix:", "host:99999", "host:nosuchservice"} {
+		if _, err := ParseListen(s); !errors.Is(err, ErrInvalidAddr) {
+			t.Errorf("%q: want ErrInvalidAddr, got %v", s, err)
+		}
+	}
+}
+
+func TestResolveListen(t *testing.T) {
+	r, err := ResolveListen("lo:9000")
+	if err != nil {
+		t.Skipf("no loopback interface: %s", err)
+	}
+
+	found := false
+	for _, l := range r {
+		if l.Address == "127.0.0.1:9000" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("lo:9000 didn't expand to 127.0.0.1:9000: %v", r)
+	}
+
+	r, err = ResolveListen("tcp4:lo:9000")
+	if err != nil {
+		t.Fatalf("tcp4: %s", err)
+	}
+	for _, l := range r {
+		if l.Address != "127.0.0.1:9000" {
+			t.Errorf("tcp4 returned %s", l)
+		}
+	}
+
+	// not an interface: passed through
+	r, err = ResolveListen("example.com:80")
+	if err != nil || len(r) != 1 || r[0].Address != "example.com:80" {
+		t.Errorf("want passthrough, got %v %v", r, err)
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.0/8":       "10.0.0.0/8",
+		"192.168.1.1":      "192.168.1.1/32",
+		"::ffff:192.0.2.1": "192.0.2.1/32",
+		"2001:db8::/32":    "2001:db8::/32",
+		" fe80::1 ":        "fe80::1/128",
+	} {
+		p, err := ParsePrefix(in)
+		if err != nil || p.String() != want {
+			t.Errorf("%q: want %s, got %s %v", in, want, p, err)
+		}
+	}
+
+	for _, s := range []string{"10.1.2.3/8", "10.0.0.0/33", "nope", ""} {
+		if _, err := ParsePrefix(s); !errors.Is(err, ErrInvalidAddr) {
+			t.Errorf("%q: want ErrInvalidAddr, got %v", s, err)
+		}
+	}
+
+	ps, err := ParsePrefixes("10.0.0.0/8, 172.16.0.0/12\n192.168.0.0/16")
+	if err != nil || len(ps) != 3 {
+		t.Errorf("want 3 prefixes, got %v %v", ps, err)
+	}
+}
+
+func TestSplitPort(t *testing.T) {
+	h, p, err := SplitPort("[::1]:8080")
+	if err != nil || h != "::1" || p != 8080 {
+		t.Errorf("got %s %d %v", h, p, err)
+	}
+	if _, _, err := SplitPort("host:http"); !errors.Is(err, ErrInvalidAddr) {
+		t.Errorf("want ErrInvalidAddr, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrShortBuffer is returned when an Unpacker runs out of input.
+	ErrShortBuffer = errors.New("pack: short buffer")
+
+	// ErrVarint is returned for a malformed or overflowing varint.
+	ErrVarint = errors.New("pack: bad varint")
+
+	// ErrTrailing is returned by Unpacker.Done when input is left over.
+	ErrTrailing = errors.New("pack: trailing data")
+)
+
+// Packer builds a binary message. Fixed size integers are big endian;
+// byte strings are prefixed with their length as a uvarint. The zero
+// value is ready to use.
+type Packer struct {
+	b []byte
+}
+
+// NewPacker returns a packer with room for n bytes.
+func NewPacker(n int) *Packer {
+	return &Packer{b: make([]byte, 0, n)}
+}
+
+// PutUint8 appends v.
+func (p *Packer) PutUint8(v uint8) *Packer {
+	p.b = append(p.b, v)
+	return p
+}
+
+// PutUint16 appends v.
+func (p *Packer) PutUint16(v uint16) *Packer {
+	p.b = binary.BigEndian.AppendUint16(p.b, v)
+	return p
+}
+
+// PutUint32 appends v.
+func (p *Packer) PutUint32(v uint32) *Packer {
+	p.b = binary.BigEndian.AppendUint32(p.b, v)
+	return p
+}
+
+// PutUint64 appends v.
+func (p *Packer) PutUint64(v uint64) *Packer {
+	p.b = binary.BigEndian.AppendUint64(p.b, v)
+	return p
+}
+
+// PutUvarint appends v as a uvarint.
+func (p *Packer) PutUvarint(v uint64) *Packer {
+	p.b = binary.AppendUvarint(p.b, v)
+	return p
+}
+
+// PutVarint appends v as a zig-zag varint.
+func (p *Packer) PutVarint(v int64) *Packer {
+	p.b = binary.AppendVarint(p.b, v)
+	return p
+}
+
+// PutBytes appends b with a length prefix.
+func (p *Packer) PutBytes(b []byte) *Packer {
+	p.b = binary.AppendUvarint(p.b, uint64(len(b)))
+	p.b = append(p.b, b...)
+	return p
+}
+
+// PutString appends s with a length prefix.
+func (p *Packer) PutString(s string) *Packer {
+	p.b = binary.AppendUvarint(p.b, uint64(len(s)))
+	p.b = append(p.b, s...)
+	return p
+}
+
+// PutRaw appends b as is.
+func (p *Packer) PutRaw(b []byte) *Packer {
+	p.b = append(p.b, b...)
+	return p
+}
+
+// Bytes returns the message; it aliases the packer's buffer.
+func (p *Packer) Bytes() []byte {
+	return p.b
+}
+
+// Len returns the size of the message.
+func (p *Packer) Len() int {
+	return len(p.b)
+}
+
+// Reset empties the packer, keeping its buffer.
+func (p *Packer) Reset() {
+	p.b = p.b[:0]
+}
+
+// Unpacker reads a message built by Packer. Every read is bounds
+// checked; the first error sticks and makes later reads return zero
+// values, so a sequence of reads needs a single check of Err at the end.
+type Unpacker struct {
+	b   []byte
+	err error
+}
+
+// NewUnpacker returns an unpacker reading b.
+func NewUnpacker(b []byte) *Unpacker {
+	return &Unpacker{b: b}
+}
+
+// Err returns the first error.
+func (u *Unpacker) Err() error {
+	return u.err
+}
+
+// Done returns the first error, or ErrTrailing if input is left.
+func (u *Unpacker) Done() error {
+	if u.err == nil && len(u.b) > 0 {
+		return ErrTrailing
+	}
+	return u.err
+}
+
+// Remaining returns the number of unread bytes.
+func (u *Unpacker) Remaining() int {
+	return len(u.b)
+}
+
+func (u *Unpacker) take(n int) []byte {
+	if u.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(u.b) {
+		u.err = ErrShortBuffer
+		return nil
+	}
+	r := u.b[:n:n]
+	u.b = u.b[n:]
+	return r
+}
+
+// Uint8 reads a byte.
+func (u *Unpacker) Uint8() uint8 {
+	if b := u.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// Uint16 reads a big endian uint16.
+func (u *Unpacker) Uint16() uint16 {
+	if b := u.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// Uint32 reads a big endian uint32.
+func (u *Unpacker) Uint32() uint32 {
+	if b := u.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// Uint64 reads a big endian uint64.
+func (u *Unpacker) Uint64() uint64 {
+	if b := u.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// Uvarint reads a uvarint.
+func (u *Unpacker) Uvarint() uint64 {
+	if u.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(u.b)
+	switch {
+	case n == 0:
+		u.err = ErrShortBuffer
+		return 0
+	case n < 0:
+		u.err = ErrVarint
+		return 0
+	}
+	u.b = u.b[n:]
+	return v
+}
+
+// Varint reads a zig-zag varint.
+func (u *Unpacker) Varint() int64 {
+	if u.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(u.b)
+	switch {
+	case n == 0:
+		u.err = ErrShortBuffer
+		return 0
+	case n < 0:
+		u.err = ErrVarint
+		return 0
+	}
+	u.b = u.b[n:]
+	return v
+}
+
+// Bytes reads a length-prefixed byte string. The result aliases the
+// input.
+func (u *Unpacker) Bytes() []byte {
+	n := u.Uvarint()
+	if n > uint64(len(u.b)) {
+		if u.err == nil {
+			u.err = ErrShortBuffer
+		}
+		return nil
+	}
+	return u.take(int(n))
+}
+
+// String reads a length-prefixed string.
+func (u *Unpacker) String() string {
+	return string(u.Bytes())
+}
+
+// Raw reads the next n bytes. The result aliases the input.
+func (u *Unpacker) Raw(n int) []byte {
+	return u.take(n)
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPack(t *testing.T) {
+	p := NewPacker(64)
+	p.PutUint8(7).PutUint16(0x1234).PutUint32(0xdeadbeef).PutUint64(1 << 60)
+	p.PutUvarint(300).PutVarint(-5).PutBytes([]byte("abc")).PutString("héllo").PutRaw([]byte{1, 2})
+
+	u := NewUnpacker(p.Bytes())
+	if u.Uint8() != 7 || u.Uint16() != 0x1234 || u.Uint32() != 0xdeadbeef || u.Uint64() != 1<<60 {
+		t.Fatalf("fixed ints mismatch")
+	}
+	if u.Uvarint() != 300 || u.Varint() != -5 {
+		t.Fatalf("varints mismatch")
+	}
+	if !bytes.Equal(u.Bytes(), []byte("abc")) || u.String() != "héllo" {
+		t.Fatalf("strings mismatch")
+	}
+	if !bytes.Equal(u.Raw(2), []byte{1, 2}) {
+		t.Fatalf("raw mismatch")
+	}
+	if err := u.Done(); err != nil {
+		t.Fatalf("done: %s", err)
+	}
+
+	p.Reset()
+	if p.Len() != 0 {
+		t.Errorf("reset didn't empty the packer")
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	u := NewUnpacker([]byte{0, 1})
+	u.Uint32()
+	if u.Err() != ErrShortBuffer {
+		t.Errorf("want ErrShortBuffer, got %v", u.Err())
+	}
+	// sticky: later reads return zero and keep the first error
+	if u.Uint8() != 0 || u.Err() != ErrShortBuffer {
+		t.Errorf("error not sticky")
+	}
+
+	// a length prefix larger than the input
+	u = NewUnpacker([]byte{0x80, 0x01, 'a'})
+	if b := u.Bytes(); b != nil || u.Err() != ErrShortBuffer {
+		t.Errorf("want ErrShortBuffer for an oversized length, got %q %v", b, u.Err())
+	}
+
+	u = NewUnpacker(bytes.Repeat([]byte{0xff}, 11))
+	u.Uvarint()
+	if u.Err() != ErrVarint {
+		t.Errorf("want ErrVarint, got %v", u.Err())
+	}
+
+	u = NewUnpacker([]byte{1, 2})
+	u.Uint8()
+	if u.Done() != ErrTrailing {
+		t.Errorf("want ErrTrailing")
+	}
+}
@@ -0,0 +1,151 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	// ErrPoolClosed is returned when submitting to a stopped pool.
+	ErrPoolClosed = errors.New("pool: closed")
+
+	// ErrPoolFull is returned by TrySubmit when the queue is full.
+	ErrPoolFull = errors.New("pool: queue full")
+)
+
+// Pool runs tasks on a fixed number of goroutines fed by a bounded queue.
+// A task that panics doesn't take down its worker; the panic is passed to
+// the pool's panic handler.
+type Pool struct {
+	q       chan func()
+	wg      sync.WaitGroup
+	onPanic func(v interface{}, stack []byte)
+
+	// quit wakes submitters blocked on a full queue so that Stop can
+	// take the lock and close q
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPool starts a pool with the given number of workers and a queue of
+// qlen pending tasks. onPanic is called with the recovered value and the
+// stack trace of a panicking task; if nil, panics are logged with the
+// standard logger.
+func NewPool(workers, qlen int, onPanic func(v interface{}, stack []byte)) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if qlen < 0 {
+		qlen = 0
+	}
+	if onPanic == nil {
+		onPanic = func(v interface{}, stack []byte) {
+			log.Printf("pool: task panic: %v\n%s", v, stack)
+		}
+	}
+
+	p := &Pool{
+		q:       make(chan func(), qlen),
+		onPanic: onPanic,
+		quit:    make(chan struct{}),
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Submit queues fn for execution, blocking while the queue is full. It
+// returns ErrPoolClosed if the pool is stopped while it waits.
+func (p *Pool) Submit(fn func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	select {
+	case <-p.quit:
+		return ErrPoolClosed
+	default:
+	}
+
+	select {
+	case p.q <- fn:
+		return nil
+	case <-p.quit:
+		return ErrPoolClosed
+	}
+}
+
+// TrySubmit queues fn without blocking; it returns ErrPoolFull if the
+// queue is full.
+func (p *Pool) TrySubmit(fn func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.q <- fn:
+		return nil
+	default:
+		return ErrPoolFull
+	}
+}
+
+// Pending returns the number of queued tasks that haven't started yet.
+func (p *Pool) Pending() int {
+	return len(p.q)
+}
+
+// Stop stops accepting new tasks, waits for the queued tasks to finish
+// and then stops the workers. It is safe to call Stop more than once.
+func (p *Pool) Stop() {
+	p.quitOnce.Do(func() { close(p.quit) })
+
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.q)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for fn := range p.q {
+		p.run(fn)
+	}
+}
+
+func (p *Pool) run(fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			p.onPanic(v, debug.Stack())
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	var panics int32
+	p := NewPool(4, 16, func(v interface{}, stack []byte) {
+		atomic.AddInt32(&panics, 1)
+	})
+
+	var n, running, maxrun int32
+	for i := 0; i < 100; i++ {
+		i := i
+		err := p.Submit(func() {
+			r := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxrun)
+				if r <= m || atomic.CompareAndSwapInt32(&maxrun, m, r) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+
+			if i%10 == 0 {
+				panic("boom")
+			}
+			atomic.AddInt32(&n, 1)
+		})
+		if err != nil {
+			t.Fatalf("submit %d: %s", i, err)
+		}
+	}
+
+	// Stop drains everything that was queued
+	p.Stop()
+	if n != 90 || panics != 10 {
+		t.Errorf("want 90 completed and 10 panics, got %d and %d", n, panics)
+	}
+	if maxrun > 4 {
+		t.Errorf("more than 4 tasks ran concurrently: %d", maxrun)
+	}
+
+	if err := p.Submit(func() {}); err != ErrPoolClosed {
+		t.Errorf("submit after stop: want ErrPoolClosed, got %v", err)
+	}
+	p.Stop()
+}
+
+func TestPoolTrySubmit(t *testing.T) {
+	p := NewPool(1, 1, nil)
+	defer p.Stop()
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit(func() {
+		close(started)
+		<-block
+	})
+	<-started
+
+	if err := p.TrySubmit(func() {}); err != nil {
+		t.Fatalf("first TrySubmit: %s", err)
+	}
+	if err := p.TrySubmit(func() {}); err != ErrPoolFull {
+		t.Errorf("want ErrPoolFull, got %v", err)
+	}
+	if p.Pending() != 1 {
+		t.Errorf("want 1 pending task, got %d", p.Pending())
+	}
+	close(block)
+}
+
+func TestPoolStopBlockedSubmit(t *testing.T) {
+	p := NewPool(1, 1, nil)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	p.Submit(func() {})
+
+	// the queue is full and the worker is busy: this one blocks
+	errc := make(chan error)
+	go func() { errc <- p.Submit(func() {}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case err := <-errc:
+		if err != ErrPoolClosed {
+			t.Errorf("blocked submit: want ErrPoolClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop didn't release a blocked Submit")
+	}
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+	if err := p.Submit(func() {}); err != ErrPoolClosed {
+		t.Errorf("submit after stop: want ErrPoolClosed, got %v", err)
+	}
+}
@@ -0,0 +1,592 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrQueueEmpty is returned by PQueue.Pop when there is nothing to
+	// read.
+	ErrQueueEmpty = errors.New("pqueue: empty")
+
+	// ErrQueueClosed is returned after PQueue.Close.
+	ErrQueueClosed = errors.New("pqueue: closed")
+
+	// ErrQueueCorrupt is returned when a record fails its checksum; the
+	// rest of that segment is skipped.
+	ErrQueueCorrupt = errors.New("pqueue: corrupt record")
+)
+
+// PQueueOpt configures a persistent queue.
+type PQueueOpt struct {
+	// SegmentSize is the size after which a new segment file is
+	// started. Default 64 MiB.
+	SegmentSize int64
+
+	// NoSync skips the fsync after every Push and Ack; a crash may then
+	// lose the most recent records or redeliver acked ones.
+	NoSync bool
+}
+
+// Item is a record read from a PQueue.
+type Item struct {
+	ID   uint64 // sequence number, for Ack
+	Data []byte
+}
+
+// PQueue is a durable FIFO backed by segment files in a directory. Each
+// record carries a CRC-32C. Delivery is at-least-once: records that were
+// popped but not acked are delivered again after a restart. It is safe
+// for concurrent use.
+type PQueue struct {
+	dir string
+	opt PQueueOpt
+
+	mu     sync.Mutex
+	closed bool
+	ready  chan struct{}
+	done   chan struct{} // closed by Close
+
+	// write side
+	w     *os.File
+	wseg  uint64
+	wsize int64
+
+	// read side
+	r    *os.File
+	rpos qpos
+
+	// popped but unacked records, in delivery order; commit is the
+	// position of the first of them (or rpos if none)
+	inflight []qflight
+	commit   qpos
+}
+
+type qpos struct {
+	seg, off, seq uint64
+}
+
+type qflight struct {
+	pos   qpos
+	acked bool
+}
+
+const (
+	qhdrLen   = 8
+	qmaxRec   = 1 << 30
+	qcommitFn = "commit"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// OpenPQueue opens or creates a queue in dir.
+func OpenPQueue(dir string, opt *PQueueOpt) (*PQueue, error) {
+	var o PQueueOpt
+	if opt != nil {
+		o = *opt
+	}
+	if o.SegmentSize <= 0 {
+		o.SegmentSize = 64 << 20
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	q := &PQueue{
+		dir:   dir,
+		opt:   o,
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	segs, err := q.segments()
+	if err != nil {
+		return nil, err
+	}
+	if err := q.readCommit(segs); err != nil {
+		return nil, err
+	}
+
+	// append to the last segment after cutting off a torn tail
+	q.wseg = q.commit.seg
+	if n := len(segs); n > 0 && segs[n-1] > q.wseg {
+		q.wseg = segs[n-1]
+	}
+	if err := q.openWriter(); err != nil {
+		return nil, err
+	}
+
+	q.rpos = q.commit
+	return q, nil
+}
+
+// Push appends a record to the queue.
+func (q *PQueue) Push(b []byte) error {
+	if len(b) > qmaxRec {
+		return fmt.Errorf("pqueue: record too large (%d bytes)", len(b))
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	if q.wsize > 0 && q.wsize+int64(qhdrLen+len(b)) > q.opt.SegmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	rec := make([]byte, qhdrLen+len(b))
+	binary.LittleEndian.PutUint32(rec[0:], uint32(len(b)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.Checksum(b, castagnoli))
+	copy(rec[qhdrLen:], b)
+
+	if _, err := q.w.Write(rec); err != nil {
+		// don't leave a partial record for the next write to follow
+		q.w.Truncate(q.wsize)
+		q.w.Seek(q.wsize, io.SeekStart)
+		return err
+	}
+	if !q.opt.NoSync {
+		if err := q.w.Sync(); err != nil {
+			return err
+		}
+	}
+	q.wsize += int64(len(rec))
+	q.signal()
+	return nil
+}
+
+// Pop returns the next record or ErrQueueEmpty. The record must be acked
+// once processed.
+func (q *PQueue) Pop() (Item, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return Item{}, ErrQueueClosed
+	}
+
+	for {
+		b, err := q.read()
+		switch err {
+		case nil:
+			it := Item{ID: q.rpos.seq, Data: b}
+			q.inflight = append(q.inflight, qflight{pos: q.rpos})
+			q.rpos.off += uint64(qhdrLen + len(b))
+			q.rpos.seq++
+
+			// PopWait callers share one wake-up; pass it on while
+			// there is more to read
+			if q.rpos.seg < q.wseg || int64(q.rpos.off) < q.wsize {
+				q.signal()
+			}
+			return it, nil
+
+		case io.EOF:
+			if q.rpos.seg >= q.wseg {
+				return Item{}, ErrQueueEmpty
+			}
+			q.nextSegment()
+
+		case ErrQueueCorrupt:
+			// the reader is about to give up on this segment, so the
+			// writer must not keep appending to it
+			bad := q.rpos
+			if bad.seg >= q.wseg {
+				if err := q.rotate(); err != nil {
+					return Item{}, err
+				}
+			}
+			q.nextSegment()
+			return Item{}, fmt.Errorf("%s offset %d: %w", q.segName(bad.seg), bad.off, err)
+
+		default:
+			return Item{}, err
+		}
+	}
+}
+
+// PopWait is like Pop but waits for a record until ctx is done or the
+// queue is closed.
+func (q *PQueue) PopWait(ctx context.Context) (Item, error) {
+	for {
+		it, err := q.Pop()
+		if err != ErrQueueEmpty {
+			return it, err
+		}
+
+		select {
+		case <-q.ready:
+		case <-q.done:
+			return Item{}, ErrQueueClosed
+		case <-ctx.Done():
+			return Item{}, ctx.Err()
+		}
+	}
+}
+
+// signal wakes one PopWait caller.
+func (q *PQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ack marks the record id as processed. Acks may arrive out of order;
+// the durable commit point only moves past a record once it and all
+// earlier ones are acked.
+func (q *PQueue) Ack(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if len(q.inflight) == 0 || id < q.inflight[0].pos.seq {
+		return nil
+	}
+
+	i := int(id - q.inflight[0].pos.seq)
+	if i >= len(q.inflight) {
+		return fmt.Errorf("pqueue: ack of unknown record %d", id)
+	}
+	q.inflight[i].acked = true
+
+	n := 0
+	for n < len(q.inflight) && q.inflight[n].acked {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	q.inflight = q.inflight[n:]
+
+	if len(q.inflight) > 0 {
+		q.commit = q.inflight[0].pos
+	} else {
+		q.commit = q.rpos
+	}
+	return q.writeCommit()
+}
+
+// Len returns the number of records not yet popped; it walks the
+// unread part of the queue.
+func (q *PQueue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for seg := q.rpos.seg; seg <= q.wseg; seg++ {
+		off := uint64(0)
+		if seg == q.rpos.seg {
+			off = q.rpos.off
+		}
+		// Pop skips whatever follows a corrupt record
+		c, _, err := q.scan(seg, off)
+		if err != nil && err != ErrQueueCorrupt && !os.IsNotExist(err) {
+			return 0, err
+		}
+		n += c
+	}
+	return n, nil
+}
+
+// Close closes the queue files. Unacked records will be delivered again
+// when the queue is reopened.
+func (q *PQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.done)
+
+	if q.r != nil {
+		q.r.Close()
+	}
+	return q.w.Close()
+}
+
+// read reads the record at rpos; it must be called with the lock held.
+func (q *PQueue) read() ([]byte, error) {
+	if q.r == nil {
+		fd, err := os.Open(q.segName(q.rpos.seg))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		q.r = fd
+	}
+
+	var hdr [qhdrLen]byte
+	if _, err := q.r.ReadAt(hdr[:], int64(q.rpos.off)); err != nil {
+		// a short header at the end is a record still being written
+		return nil, io.EOF
+	}
+
+	n := binary.LittleEndian.Uint32(hdr[0:])
+	if n > qmaxRec {
+		return nil, ErrQueueCorrupt
+	}
+
+	b := make([]byte, n)
+	if _, err := q.r.ReadAt(b, int64(q.rpos.off)+qhdrLen); err != nil {
+		return nil, io.EOF
+	}
+	if crc32.Checksum(b, castagnoli) != binary.LittleEndian.Uint32(hdr[4:]) {
+		return nil, ErrQueueCorrupt
+	}
+	return b, nil
+}
+
+func (q *PQueue) nextSegment() {
+	if q.r != nil {
+		q.r.Close()
+		q.r = nil
+	}
+	q.rpos.seg++
+	q.rpos.off = 0
+}
+
+// rotate starts a new write segment.
+func (q *PQueue) rotate() error {
+	if err := q.w.Close(); err != nil {
+		return err
+	}
+	q.wseg++
+	return q.openWriter()
+}
+
+// openWriter opens segment wseg for appending, cutting off any torn
+// record at its end. A segment with a corrupt record before its end is
+// left alone for the reader to report, and writing moves on to a new
+// segment.
+func (q *PQueue) openWriter() error {
+	fn := q.segName(q.wseg)
+	fd, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, end, err := q.scan(q.wseg, 0)
+	if err == ErrQueueCorrupt {
+		fd.Close()
+		q.wseg++
+		return q.openWriter()
+	}
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	if err := fd.Truncate(int64(end)); err != nil {
+		fd.Close()
+		return err
+	}
+	if _, err := fd.Seek(int64(end), io.SeekStart); err != nil {
+		fd.Close()
+		return err
+	}
+
+	q.w = fd
+	q.wsize = int64(end)
+	return nil
+}
+
+// scan counts the valid records of a segment from off and returns the
+// offset after the last one. A bad record at the end of the file is a
+// torn write and ends the scan quietly; one with data after it is
+// corruption and returns ErrQueueCorrupt.
+func (q *PQueue) scan(seg, off uint64) (int, uint64, error) {
+	fd, err := os.Open(q.segName(seg))
+	if err != nil {
+		return 0, off, err
+	}
+	defer fd.Close()
+
+	fi, err := fd.Stat()
+	if err != nil {
+		return 0, off, err
+	}
+	size := uint64(fi.Size())
+
+	n := 0
+	var hdr [qhdrLen]byte
+	for {
+		if _, err := fd.ReadAt(hdr[:], int64(off)); err != nil {
+			return n, off, nil
+		}
+
+		// a torn write leaves a prefix of the real record, so an
+		// impossible length can only be damage
+		sz := binary.LittleEndian.Uint32(hdr[0:])
+		if sz > qmaxRec {
+			return n, off, ErrQueueCorrupt
+		}
+		end := off + uint64(qhdrLen) + uint64(sz)
+		if end > size {
+			return n, off, nil
+		}
+
+		b := make([]byte, sz)
+		if _, err := fd.ReadAt(b, int64(off)+qhdrLen); err != nil {
+			return n, off, err
+		}
+		if crc32.Checksum(b, castagnoli) != binary.LittleEndian.Uint32(hdr[4:]) {
+			if end == size {
+				return n, off, nil
+			}
+			return n, off, ErrQueueCorrupt
+		}
+		off = end
+		n++
+	}
+}
+
+func (q *PQueue) segName(seg uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d.seg", seg))
+}
+
+// segments returns the segment numbers in dir in order.
+func (q *PQueue) segments() ([]uint64, error) {
+	ents, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []uint64
+	for _, e := range ents {
+		nm, ok := strings.CutSuffix(e.Name(), ".seg")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(nm, 10, 64); err == nil {
+			r = append(r, n)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r, nil
+}
+
+// readCommit loads the commit point; without one, reading starts at the
+// oldest segment.
+func (q *PQueue) readCommit(segs []uint64) error {
+	b, err := os.ReadFile(filepath.Join(q.dir, qcommitFn))
+	if os.IsNotExist(err) {
+		if len(segs) > 0 {
+			q.commit.seg = segs[0]
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(b) != 28 || crc32.Checksum(b[:24], castagnoli) != binary.LittleEndian.Uint32(b[24:]) {
+		return fmt.Errorf("%s: %w", qcommitFn, ErrQueueCorrupt)
+	}
+	q.commit = qpos{
+		seg: binary.LittleEndian.Uint64(b[0:]),
+		off: binary.LittleEndian.Uint64(b[8:]),
+		seq: binary.LittleEndian.Uint64(b[16:]),
+	}
+	return nil
+}
+
+// writeCommit atomically replaces the commit file and removes the
+// segments before the commit point. It must be called with the lock held.
+func (q *PQueue) writeCommit() error {
+	var b [28]byte
+	binary.LittleEndian.PutUint64(b[0:], q.commit.seg)
+	binary.LittleEndian.PutUint64(b[8:], q.commit.off)
+	binary.LittleEndian.PutUint64(b[16:], q.commit.seq)
+	binary.LittleEndian.PutUint32(b[24:], crc32.Checksum(b[:24], castagnoli))
+
+	fn := filepath.Join(q.dir, qcommitFn)
+	tmp := fn + ".tmp"
+	fd, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fd.Write(b[:])
+	if err == nil && !q.opt.NoSync {
+		err = fd.Sync()
+	}
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if !q.opt.NoSync {
+		// the rename isn't durable until the directory is synced
+		if err := syncDir(q.dir); err != nil {
+			return err
+		}
+	}
+
+	segs, err := q.segments()
+	if err != nil {
+		return err
+	}
+	for _, s := range segs {
+		if s >= q.commit.seg {
+			break
+		}
+		os.Remove(q.segName(s))
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory so that entries renamed in it are durable.
+// It is a no-op on platforms that can't sync directories.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return fd.Sync()
+}
@@ -0,0 +1,323 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func popString(t *testing.T, q *PQueue) (uint64, string) {
+	t.Helper()
+	it, err := q.Pop()
+	if err != nil {
+		t.Fatalf("pop: %s", err)
+	}
+	return it.ID, string(it.Data)
+}
+
+func TestPQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenPQueue(dir, nil)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+
+	if _, err := q.Pop(); err != ErrQueueEmpty {
+		t.Fatalf("want ErrQueueEmpty, got %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		q.Push([]byte(fmt.Sprintf("rec%d", i)))
+	}
+	if n, _ := q.Len(); n != 5 {
+		t.Errorf("want len 5, got %d", n)
+	}
+
+	id0, s := popString(t, q)
+	if s != "rec0" {
+		t.Fatalf("want rec0, got %s", s)
+	}
+	id1, _ := popString(t, q)
+	id2, _ := popString(t, q)
+
+	// ack 0 and 2; 1 is still outstanding
+	q.Ack(id0)
+	q.Ack(id2)
+	q.Close()
+
+	if _, err := q.Pop(); err != ErrQueueClosed {
+		t.Errorf("want ErrQueueClosed, got %v", err)
+	}
+
+	// after a restart delivery resumes at the first unacked record
+	q, err = OpenPQueue(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: %s", err)
+	}
+	defer q.Close()
+
+	id, s := popString(t, q)
+	if id != id1 || s != "rec1" {
+		t.Fatalf("want rec1 redelivered as %d, got %d %s", id1, id, s)
+	}
+	for _, want := range []string{"rec2", "rec3", "rec4"} {
+		id, s := popString(t, q)
+		if s != want {
+			t.Fatalf("want %s, got %s", want, s)
+		}
+		q.Ack(id)
+	}
+	q.Ack(id)
+
+	if err := q.Push([]byte("more")); err != nil {
+		t.Fatalf("push: %s", err)
+	}
+	if _, s := popString(t, q); s != "more" {
+		t.Errorf("want more, got %s", s)
+	}
+}
+
+func TestPQueueSegments(t *testing.T) {
+	dir := t.TempDir()
+	q, err := OpenPQueue(dir, &PQueueOpt{SegmentSize: 64, NoSync: true})
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 20; i++ {
+		q.Push([]byte(fmt.Sprintf("record-%02d", i)))
+	}
+	segs, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segs) < 5 {
+		t.Fatalf("want several segments, got %d", len(segs))
+	}
+
+	for i := 0; i < 20; i++ {
+		id, s := popString(t, q)
+		if want := fmt.Sprintf("record-%02d", i); s != want {
+			t.Fatalf("want %s, got %s", want, s)
+		}
+		if err := q.Ack(id); err != nil {
+			t.Fatalf("ack: %s", err)
+		}
+	}
+
+	// fully consumed segments are removed
+	segs, _ = filepath.Glob(filepath.Join(dir, "*.seg"))
+	if len(segs) != 1 {
+		t.Errorf("want 1 segment left, got %v", segs)
+	}
+}
+
+func TestPQueueTornTail(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := OpenPQueue(dir, nil)
+	q.Push([]byte("good"))
+	q.Close()
+
+	// simulate a crash in the middle of a write
+	fn := filepath.Join(dir, fmt.Sprintf("%020d.seg", 0))
+	fd, _ := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND, 0)
+	fd.Write([]byte{100, 0, 0, 0, 1, 2, 3, 4, 'x'})
+	fd.Close()
+
+	q, err := OpenPQueue(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: %s", err)
+	}
+	defer q.Close()
+
+	q.Push([]byte("after"))
+	for _, want := range []string{"good", "after"} {
+		if _, s := popString(t, q); s != want {
+			t.Fatalf("want %s, got %s", want, s)
+		}
+	}
+}
+
+func TestPQueueCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := OpenPQueue(dir, &PQueueOpt{SegmentSize: 30})
+	q.Push([]byte("first-record"))
+	q.Push([]byte("second-record"))
+	q.Close()
+
+	// flip a payload byte in the first segment
+	fn := filepath.Join(dir, fmt.Sprintf("%020d.seg", 0))
+	b, _ := os.ReadFile(fn)
+	b[10] ^= 0xff
+	os.WriteFile(fn, b, 0600)
+
+	q, _ = OpenPQueue(dir, &PQueueOpt{SegmentSize: 30})
+	defer q.Close()
+
+	if _, err := q.Pop(); !errors.Is(err, ErrQueueCorrupt) {
+		t.Fatalf("want ErrQueueCorrupt, got %v", err)
+	}
+	if _, s := popString(t, q); s != "second-record" {
+		t.Errorf("want second-record after the corrupt one, got %s", s)
+	}
+}
+
+func TestPQueueCorruptActive(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := OpenPQueue(dir, nil)
+	defer q.Close()
+
+	q.Push([]byte("a"))
+	q.Push([]byte("b"))
+
+	// corrupt "a" while its segment is still being written
+	fn := filepath.Join(dir, fmt.Sprintf("%020d.seg", 0))
+	b, _ := os.ReadFile(fn)
+	b[qhdrLen] ^= 0xff
+	os.WriteFile(fn, b, 0600)
+
+	if _, err := q.Pop(); !errors.Is(err, ErrQueueCorrupt) {
+		t.Fatalf("want ErrQueueCorrupt, got %v", err)
+	}
+
+	// later records must not land in the abandoned segment
+	q.Push([]byte("c"))
+	if n, err := q.Len(); err != nil || n != 1 {
+		t.Errorf("want Len 1, got %d, %v", n, err)
+	}
+	id, s := popString(t, q)
+	if s != "c" {
+		t.Fatalf("want c, got %s", s)
+	}
+	if err := q.Ack(id); err != nil {
+		t.Fatalf("ack: %s", err)
+	}
+
+	q.Push([]byte("d"))
+	if _, s := popString(t, q); s != "d" {
+		t.Errorf("want d after the ack, got %s", s)
+	}
+}
+
+func TestPQueueCorruptReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := OpenPQueue(dir, nil)
+	for _, s := range []string{"a", "b", "c"} {
+		q.Push([]byte(s))
+	}
+	q.Close()
+
+	// damage "b"; "c" after it must survive the reopen
+	fn := filepath.Join(dir, fmt.Sprintf("%020d.seg", 0))
+	b, _ := os.ReadFile(fn)
+	b[2*qhdrLen+1] ^= 0xff
+	os.WriteFile(fn, b, 0600)
+
+	q, err := OpenPQueue(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen: %s", err)
+	}
+	defer q.Close()
+
+	if fi, _ := os.Stat(fn); fi.Size() != int64(len(b)) {
+		t.Errorf("segment truncated from %d to %d bytes", len(b), fi.Size())
+	}
+
+	q.Push([]byte("d"))
+	if _, s := popString(t, q); s != "a" {
+		t.Fatalf("want a, got %s", s)
+	}
+	if _, err := q.Pop(); !errors.Is(err, ErrQueueCorrupt) {
+		t.Fatalf("want ErrQueueCorrupt, got %v", err)
+	}
+	if _, s := popString(t, q); s != "d" {
+		t.Errorf("want d, got %s", s)
+	}
+}
+
+func TestPQueuePopWait(t *testing.T) {
+	q, _ := OpenPQueue(t.TempDir(), nil)
+	defer q.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push([]byte("late"))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	it, err := q.PopWait(ctx)
+	if err != nil || string(it.Data) != "late" {
+		t.Fatalf("got %q %v", it.Data, err)
+	}
+
+	ctx, cancel2 := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel2()
+	if _, err := q.PopWait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("want DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestPQueuePopWaitMany(t *testing.T) {
+	q, _ := OpenPQueue(t.TempDir(), &PQueueOpt{NoSync: true})
+	defer q.Close()
+
+	// a consumer that took the only wake-up passes it on while records
+	// remain, or other waiters would sleep on a non-empty queue
+	q.Push([]byte("a"))
+	q.Push([]byte("b"))
+	<-q.ready
+	popString(t, q)
+	select {
+	case <-q.ready:
+	default:
+		t.Fatalf("wake-up not passed on")
+	}
+	popString(t, q)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	const n = 4
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := q.PopWait(ctx)
+			errc <- err
+		}()
+	}
+
+	// let the consumers block, then push one record for each
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < n; i++ {
+		q.Push([]byte(fmt.Sprintf("record-%d", i)))
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errc; err != nil {
+			t.Fatalf("consumer %d: %v", i, err)
+		}
+	}
+}
+
+func TestPQueuePopWaitClose(t *testing.T) {
+	q, _ := OpenPQueue(t.TempDir(), nil)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := q.PopWait(context.Background())
+		errc <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	select {
+	case err := <-errc:
+		if err != ErrQueueClosed {
+			t.Errorf("want ErrQueueClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("PopWait not woken by Close")
+	}
+}
@@ -0,0 +1,170 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Ring is a consistent hash ring. Each member is placed on the ring at
+// many points (virtual nodes) in proportion to its weight; a key belongs
+// to the member owning the first point at or after the key's hash. Adding
+// or removing a member only moves the keys that member gains or loses.
+// The placement is deterministic, so every process with the same members
+// maps keys identically. It is safe for concurrent use.
+type Ring struct {
+	mu sync.RWMutex
+
+	vnodes  int
+	members map[string]int
+	points  []ringPoint
+}
+
+type ringPoint struct {
+	hash uint64
+	name string
+}
+
+// NewRing returns an empty ring that places vnodes points on the ring
+// for every unit of member weight.
+func NewRing(vnodes int) *Ring {
+	if vnodes <= 0 {
+		vnodes = 128
+	}
+
+	r := &Ring{
+		vnodes:  vnodes,
+		members: make(map[string]int),
+	}
+	return r
+}
+
+// Add adds a member with the given weight or changes the weight of an
+// existing member. A weight of zero or less removes the member.
+func (r *Ring) Add(name string, weight int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if weight <= 0 {
+		delete(r.members, name)
+	} else {
+		r.members[name] = weight
+	}
+	r.rebuild()
+}
+
+// Remove removes a member from the ring.
+func (r *Ring) Remove(name string) {
+	r.Add(name, 0)
+}
+
+// Members returns the names of the members in sorted order.
+func (r *Ring) Members() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	v := make([]string, 0, len(r.members))
+	for nm := range r.members {
+		v = append(v, nm)
+	}
+	sort.Strings(v)
+	return v
+}
+
+// Get returns the member that owns key. It returns false if the ring is
+// empty.
+func (r *Ring) Get(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.points) == 0 {
+		return "", false
+	}
+	return r.points[r.search(ringHash(key))].name, true
+}
+
+// GetN returns up to n distinct members for key in ring order, e.g. to
+// choose replicas. The first member is the one returned by Get.
+func (r *Ring) GetN(key string, n int) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if n > len(r.members) {
+		n = len(r.members)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	v := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i, j := 0, r.search(ringHash(key)); len(v) < n && i < len(r.points); i++ {
+		p := r.points[(j+i)%len(r.points)]
+		if !seen[p.name] {
+			seen[p.name] = true
+			v = append(v, p.name)
+		}
+	}
+	return v
+}
+
+// search returns the index of the first point at or after h.
+func (r *Ring) search(h uint64) int {
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return i
+}
+
+func (r *Ring) rebuild() {
+	n := 0
+	for _, w := range r.members {
+		n += w * r.vnodes
+	}
+
+	pts := make([]ringPoint, 0, n)
+	for nm, w := range r.members {
+		for i := 0; i < w*r.vnodes; i++ {
+			pts = append(pts, ringPoint{ringHash(nm + "#" + strconv.Itoa(i)), nm})
+		}
+	}
+
+	// ties are broken by name so that the ring doesn't depend on map
+	// iteration order
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].hash != pts[j].hash {
+			return pts[i].hash < pts[j].hash
+		}
+		return pts[i].name < pts[j].name
+	})
+	r.points = pts
+}
+
+// ringHash is FNV-1a followed by a 64-bit finalizer; FNV alone clusters
+// badly for the similar strings used to name virtual nodes.
+func ringHash(s string) uint64 {
+	x := FNV1a64String(s)
+
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+)
+
+func ringOwners(r *Ring, n int) map[string]string {
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k := fmt.Sprintf("key-%d", i)
+		m[k], _ = r.Get(k)
+	}
+	return m
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing(100)
+	if _, ok := r.Get("x"); ok {
+		t.Fatalf("empty ring returned a member")
+	}
+
+	for i := 0; i < 5; i++ {
+		r.Add(fmt.Sprintf("node%d", i), 1)
+	}
+
+	const nkeys = 20000
+	before := ringOwners(r, nkeys)
+
+	counts := make(map[string]int)
+	for _, o := range before {
+		counts[o]++
+	}
+	for nm, n := range counts {
+		if n < nkeys/5*7/10 || n > nkeys/5*13/10 {
+			t.Errorf("%s owns %d of %d keys", nm, n, nkeys)
+		}
+	}
+
+	// adding a member only moves keys to that member
+	r.Add("node5", 1)
+	after := ringOwners(r, nkeys)
+
+	var moved int
+	for k, o := range after {
+		if o != before[k] {
+			moved++
+			if o != "node5" {
+				t.Fatalf("%s moved from %s to %s", k, before[k], o)
+			}
+		}
+	}
+	if moved < nkeys/6*7/10 || moved > nkeys/6*13/10 {
+		t.Errorf("adding a member moved %d of %d keys", moved, nkeys)
+	}
+
+	// removing it restores the original mapping
+	r.Remove("node5")
+	for k, o := range ringOwners(r, nkeys) {
+		if o != before[k] {
+			t.Fatalf("%s: want %s after remove, got %s", k, before[k], o)
+		}
+	}
+}
+
+func TestRingWeights(t *testing.T) {
+	r := NewRing(50)
+	r.Add("small", 1)
+	r.Add("big", 3)
+
+	counts := make(map[string]int)
+	for _, o := range ringOwners(r, 20000) {
+		counts[o]++
+	}
+	ratio := float64(counts["big"]) / float64(counts["small"])
+	if ratio < 2.4 || ratio > 3.6 {
+		t.Errorf("want a ~3:1 split, got %v", counts)
+	}
+}
+
+func TestRingGetN(t *testing.T) {
+	r := NewRing(10)
+	r.Add("a", 1)
+	r.Add("b", 1)
+	r.Add("c", 1)
+
+	v := r.GetN("some key", 5)
+	if len(v) != 3 {
+		t.Fatalf("want 3 distinct members, got %v", v)
+	}
+	if first, _ := r.Get("some key"); v[0] != first {
+		t.Errorf("GetN[0] = %s, Get = %s", v[0], first)
+	}
+	if v[0] == v[1] || v[1] == v[2] || v[0] == v[2] {
+		t.Errorf("duplicate members: %v", v)
+	}
+}
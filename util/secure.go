@@ -0,0 +1,119 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrSecureSize is returned by NewSecureBytes for a negative size.
+var ErrSecureSize = errors.New("secure: invalid size")
+
+// SecureBytes holds secrets such as private keys and passwords outside
+// the Go heap: the memory is page aligned, locked against swapping where
+// the platform allows it, and zeroed when the buffer is closed or
+// garbage collected. A SecureBytes must not be copied (go vet flags
+// copies) and formats as a redacted placeholder, so it doesn't leak into
+// logs by accident.
+type SecureBytes struct {
+	_ noCopy
+
+	mu     sync.Mutex
+	mem    []byte // the whole mapping
+	b      []byte // the caller's view of mem
+	locked bool
+}
+
+// NewSecureBytes allocates a zeroed secure buffer of n bytes.
+func NewSecureBytes(n int) (*SecureBytes, error) {
+	if n < 0 {
+		return nil, ErrSecureSize
+	}
+
+	mem, locked, err := secureAlloc(n)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SecureBytes{
+		mem:    mem,
+		b:      mem[:n:n],
+		locked: locked,
+	}
+	runtime.SetFinalizer(s, (*SecureBytes).Close)
+	return s, nil
+}
+
+// SecureCopy moves src into a new secure buffer and zeroes src.
+func SecureCopy(src []byte) (*SecureBytes, error) {
+	s, err := NewSecureBytes(len(src))
+	if err != nil {
+		return nil, err
+	}
+	copy(s.b, src)
+	clear(src)
+	return s, nil
+}
+
+// Bytes returns the buffer. The slice must not be retained after Close.
+func (s *SecureBytes) Bytes() []byte {
+	return s.b
+}
+
+// Len returns the size of the buffer.
+func (s *SecureBytes) Len() int {
+	return len(s.b)
+}
+
+// Locked reports whether the memory is locked against swapping; locking
+// is best effort and fails if it exceeds RLIMIT_MEMLOCK.
+func (s *SecureBytes) Locked() bool {
+	return s.locked
+}
+
+// Close zeroes and releases the buffer. It is safe to call Close more
+// than once.
+func (s *SecureBytes) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.mem == nil {
+		return nil
+	}
+
+	clear(s.mem)
+	err := secureFree(s.mem, s.locked)
+	s.mem, s.b = nil, nil
+	runtime.SetFinalizer(s, nil)
+	return err
+}
+
+// String returns a placeholder rather than the contents.
+func (s *SecureBytes) String() string {
+	return "SecureBytes(redacted)"
+}
+
+// GoString is like String, for %#v.
+func (s *SecureBytes) GoString() string {
+	return s.String()
+}
+
+// noCopy makes go vet's copylocks check flag copies of the containing
+// struct.
+type noCopy struct{}
+
+func (*noCopy) Lock()   {}
+func (*noCopy) Unlock() {}
@@ -0,0 +1,26 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !linux && !darwin
+
+package util
+
+// Without mmap/mlock the buffer lives on the heap; it is still zeroed on
+// Close.
+func secureAlloc(n int) ([]byte, bool, error) {
+	return make([]byte, n), false, nil
+}
+
+func secureFree(mem []byte, locked bool) error {
+	return nil
+}
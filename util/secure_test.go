@@ -0,0 +1,60 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSecureBytes(t *testing.T) {
+	s, err := NewSecureBytes(100)
+	if err != nil {
+		t.Fatalf("alloc: %s", err)
+	}
+	if s.Len() != 100 || cap(s.Bytes()) != 100 {
+		t.Fatalf("want 100 bytes, got len %d cap %d", s.Len(), cap(s.Bytes()))
+	}
+
+	b := s.Bytes()
+	copy(b, "hunter2")
+
+	if str := fmt.Sprintf("%v %s %#v", s, s, s); str != "SecureBytes(redacted) SecureBytes(redacted) SecureBytes(redacted)" {
+		t.Errorf("contents leaked through formatting: %s", str)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %s", err)
+	}
+	if s.Len() != 0 || s.Bytes() != nil {
+		t.Errorf("buffer still accessible after close")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second close: %s", err)
+	}
+
+	if _, err := NewSecureBytes(-1); err != ErrSecureSize {
+		t.Errorf("negative size: want ErrSecureSize, got %v", err)
+	}
+	z, err := NewSecureBytes(0)
+	if err != nil || z.Len() != 0 {
+		t.Fatalf("empty buffer: %v", err)
+	}
+	z.Close()
+}
+
+func TestSecureCopy(t *testing.T) {
+	pw := []byte("correct horse battery staple")
+	s, err := SecureCopy(pw)
+	if err != nil {
+		t.Fatalf("copy: %s", err)
+	}
+	defer s.Close()
+
+	if string(s.Bytes()) != "correct horse battery staple" {
+		t.Errorf("bad copy: %q", s.Bytes())
+	}
+	for _, c := range pw {
+		if c != 0 {
+			t.Fatalf("source not wiped: %q", pw)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build linux || darwin
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+// secureAlloc maps anonymous pages for n bytes and tries to lock them.
+func secureAlloc(n int) ([]byte, bool, error) {
+	pg := os.Getpagesize()
+	sz := (n + pg - 1) / pg * pg
+	if sz == 0 {
+		sz = pg
+	}
+
+	mem, err := syscall.Mmap(-1, 0, sz, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, false, err
+	}
+
+	locked := syscall.Mlock(mem) == nil
+	return mem, locked, nil
+}
+
+func secureFree(mem []byte, locked bool) error {
+	if locked {
+		syscall.Munlock(mem)
+	}
+	return syscall.Munmap(mem)
+}
@@ -0,0 +1,111 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(10)
+	ctx := context.Background()
+
+	if err := s.Acquire(ctx, 7); err != nil {
+		t.Fatalf("acquire: %s", err)
+	}
+	if s.TryAcquire(4) {
+		t.Fatalf("TryAcquire(4) succeeded with 3 left")
+	}
+	if !s.TryAcquire(3) {
+		t.Fatalf("TryAcquire(3) failed with 3 left")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Acquire(ctx, 5)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("acquire didn't block")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	s.Release(5)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("acquire not woken by release")
+	}
+}
+
+func TestSemaphoreCancel(t *testing.T) {
+	s := NewSemaphore(2)
+	s.Acquire(context.Background(), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+
+	// the cancelled waiter must not hold or block anything
+	s.Release(2)
+	if !s.TryAcquire(2) {
+		t.Errorf("units leaked by a cancelled acquire")
+	}
+}
+
+func TestSemaphoreFIFO(t *testing.T) {
+	s := NewSemaphore(4)
+	ctx := context.Background()
+	s.Acquire(ctx, 4)
+
+	// a big waiter queued first isn't overtaken by small ones
+	big := make(chan struct{})
+	go func() {
+		s.Acquire(ctx, 4)
+		close(big)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if s.TryAcquire(1) {
+		t.Fatalf("TryAcquire jumped the queue")
+	}
+
+	s.Release(4)
+	<-big
+}
+
+func TestSemaphoreConcurrent(t *testing.T) {
+	s := NewSemaphore(3)
+	ctx := context.Background()
+
+	var cur, max int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Acquire(ctx, 1)
+			n := atomic.AddInt32(&cur, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&cur, -1)
+			s.Release(1)
+		}()
+	}
+	wg.Wait()
+
+	if max > 3 {
+		t.Errorf("more than 3 holders at once: %d", max)
+	}
+}
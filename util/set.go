@@ -0,0 +1,242 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"iter"
+)
+
+// Set is an unordered set. The zero value is not usable; create one with
+// NewSet or make. It is not safe for concurrent use.
+type Set[T comparable] map[T]struct{}
+
+// NewSet returns a set holding items.
+func NewSet[T comparable](items ...T) Set[T] {
+	s := make(Set[T], len(items))
+	s.Add(items...)
+	return s
+}
+
+// Add adds items to the set.
+func (s Set[T]) Add(items ...T) {
+	for _, v := range items {
+		s[v] = struct{}{}
+	}
+}
+
+// Remove removes items from the set.
+func (s Set[T]) Remove(items ...T) {
+	for _, v := range items {
+		delete(s, v)
+	}
+}
+
+// Contains reports whether v is in the set.
+func (s Set[T]) Contains(v T) bool {
+	_, ok := s[v]
+	return ok
+}
+
+// Len returns the number of elements.
+func (s Set[T]) Len() int {
+	return len(s)
+}
+
+// All iterates over the elements in no particular order.
+func (s Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range s {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Slice returns the elements in no particular order.
+func (s Set[T]) Slice() []T {
+	r := make([]T, 0, len(s))
+	for v := range s {
+		r = append(r, v)
+	}
+	return r
+}
+
+// Equal reports whether s and o hold the same elements.
+func (s Set[T]) Equal(o Set[T]) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for v := range s {
+		if _, ok := o[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns a new set with the elements of s and o.
+func (s Set[T]) Union(o Set[T]) Set[T] {
+	r := make(Set[T], max(len(s), len(o)))
+	for v := range s {
+		r[v] = struct{}{}
+	}
+	for v := range o {
+		r[v] = struct{}{}
+	}
+	return r
+}
+
+// Intersect returns a new set with the elements in both s and o.
+func (s Set[T]) Intersect(o Set[T]) Set[T] {
+	a, b := s, o
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	r := make(Set[T])
+	for v := range a {
+		if _, ok := b[v]; ok {
+			r[v] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Difference returns a new set with the elements of s that aren't in o.
+func (s Set[T]) Difference(o Set[T]) Set[T] {
+	r := make(Set[T])
+	for v := range s {
+		if _, ok := o[v]; !ok {
+			r[v] = struct{}{}
+		}
+	}
+	return r
+}
+
+// OrderedSet is a set that iterates in insertion order. Re-adding an
+// existing element keeps its original position. It is not safe for
+// concurrent use.
+type OrderedSet[T comparable] struct {
+	m map[T]*setNode[T]
+
+	// sentinel of a circular list; head.next is the oldest element
+	head setNode[T]
+}
+
+type setNode[T comparable] struct {
+	v          T
+	prev, next *setNode[T]
+}
+
+// NewOrderedSet returns an ordered set holding items.
+func NewOrderedSet[T comparable](items ...T) *OrderedSet[T] {
+	s := &OrderedSet[T]{
+		m: make(map[T]*setNode[T], len(items)),
+	}
+	s.head.next = &s.head
+	s.head.prev = &s.head
+	s.Add(items...)
+	return s
+}
+
+// Add appends the items that aren't already in the set.
+func (s *OrderedSet[T]) Add(items ...T) {
+	for _, v := range items {
+		if _, ok := s.m[v]; ok {
+			continue
+		}
+
+		n := &setNode[T]{v: v, prev: s.head.prev, next: &s.head}
+		n.prev.next = n
+		s.head.prev = n
+		s.m[v] = n
+	}
+}
+
+// Remove removes items from the set.
+func (s *OrderedSet[T]) Remove(items ...T) {
+	for _, v := range items {
+		if n, ok := s.m[v]; ok {
+			n.prev.next = n.next
+			n.next.prev = n.prev
+			delete(s.m, v)
+		}
+	}
+}
+
+// Contains reports whether v is in the set.
+func (s *OrderedSet[T]) Contains(v T) bool {
+	_, ok := s.m[v]
+	return ok
+}
+
+// Len returns the number of elements.
+func (s *OrderedSet[T]) Len() int {
+	return len(s.m)
+}
+
+// All iterates over the elements in insertion order. The set must not be
+// modified during the iteration.
+func (s *OrderedSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.head.next; n != &s.head; n = n.next {
+			if !yield(n.v) {
+				return
+			}
+		}
+	}
+}
+
+// Slice returns the elements in insertion order.
+func (s *OrderedSet[T]) Slice() []T {
+	r := make([]T, 0, len(s.m))
+	for v := range s.All() {
+		r = append(r, v)
+	}
+	return r
+}
+
+// Union returns a new set with the elements of s followed by those of o.
+func (s *OrderedSet[T]) Union(o *OrderedSet[T]) *OrderedSet[T] {
+	r := NewOrderedSet(s.Slice()...)
+	for v := range o.All() {
+		r.Add(v)
+	}
+	return r
+}
+
+// Intersect returns a new set with the elements of s that are also in o,
+// in the order of s.
+func (s *OrderedSet[T]) Intersect(o *OrderedSet[T]) *OrderedSet[T] {
+	r := NewOrderedSet[T]()
+	for v := range s.All() {
+		if o.Contains(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
+
+// Difference returns a new set with the elements of s that aren't in o,
+// in the order of s.
+func (s *OrderedSet[T]) Difference(o *OrderedSet[T]) *OrderedSet[T] {
+	r := NewOrderedSet[T]()
+	for v := range s.All() {
+		if !o.Contains(v) {
+			r.Add(v)
+		}
+	}
+	return r
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	a := NewSet(1, 2, 3, 4)
+	b := NewSet(3, 4, 5)
+
+	if !a.Contains(2) || a.Contains(5) || a.Len() != 4 {
+		t.Fatalf("bad set: %v", a)
+	}
+
+	if u := a.Union(b); !u.Equal(NewSet(1, 2, 3, 4, 5)) {
+		t.Errorf("union: got %v", u)
+	}
+	if i := a.Intersect(b); !i.Equal(NewSet(3, 4)) {
+		t.Errorf("intersect: got %v", i)
+	}
+	if d := a.Difference(b); !d.Equal(NewSet(1, 2)) {
+		t.Errorf("difference: got %v", d)
+	}
+
+	a.Remove(1, 2)
+	s := a.Slice()
+	slices.Sort(s)
+	if !slices.Equal(s, []int{3, 4}) {
+		t.Errorf("after remove: got %v", s)
+	}
+
+	n := 0
+	for range b.All() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("iteration didn't stop")
+	}
+}
+
+func TestOrderedSet(t *testing.T) {
+	s := NewOrderedSet("c", "a", "b", "a")
+	if got := s.Slice(); !slices.Equal(got, []string{"c", "a", "b"}) {
+		t.Fatalf("want [c a b], got %v", got)
+	}
+
+	s.Remove("a")
+	s.Add("d", "c")
+	if got := s.Slice(); !slices.Equal(got, []string{"c", "b", "d"}) {
+		t.Errorf("want [c b d], got %v", got)
+	}
+	if s.Contains("a") || !s.Contains("d") || s.Len() != 3 {
+		t.Errorf("bad membership")
+	}
+
+	o := NewOrderedSet("x", "d", "c")
+	if got := s.Union(o).Slice(); !slices.Equal(got, []string{"c", "b", "d", "x"}) {
+		t.Errorf("union: got %v", got)
+	}
+	if got := s.Intersect(o).Slice(); !slices.Equal(got, []string{"c", "d"}) {
+		t.Errorf("intersect: got %v", got)
+	}
+	if got := s.Difference(o).Slice(); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("difference: got %v", got)
+	}
+}
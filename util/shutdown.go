@@ -0,0 +1,175 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// Shutdown runs ordered shutdown hooks when the process receives SIGINT
+// or SIGTERM and, on Unix, reload hooks on SIGHUP. Hooks run one at a time in the
+// order they were registered, all under a common deadline; a second
+// SIGINT/SIGTERM during shutdown cancels the remaining hooks' context.
+type Shutdown struct {
+	timeout time.Duration
+	sigs    chan os.Signal
+	trigger chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	waitOnce sync.Once
+	err      error // result of the hooks, set once done is closed
+
+	mu     sync.Mutex
+	hooks  []shutdownHook
+	reload []func()
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewShutdown starts catching the shutdown and reload signals; hooks get
+// at most timeout to finish in total.
+func NewShutdown(timeout time.Duration) *Shutdown {
+	s := &Shutdown{
+		timeout: timeout,
+		sigs:    make(chan os.Signal, 4),
+		trigger: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	signal.Notify(s.sigs, shutdownSignals...)
+	return s
+}
+
+// OnShutdown registers a hook; name identifies it in errors. The hook
+// should return promptly once ctx is done.
+func (s *Shutdown) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	s.hooks = append(s.hooks, shutdownHook{name, fn})
+	s.mu.Unlock()
+}
+
+// OnReload registers a function called on SIGHUP. Other systems have no
+// reload signal, so fn is never called there.
+func (s *Shutdown) OnReload(fn func()) {
+	s.mu.Lock()
+	s.reload = append(s.reload, fn)
+	s.mu.Unlock()
+}
+
+// Shutdown starts the shutdown without a signal. It is safe to call more
+// than once.
+func (s *Shutdown) Shutdown() {
+	s.once.Do(func() { close(s.trigger) })
+}
+
+// Done is closed once all shutdown hooks have run.
+func (s *Shutdown) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait handles signals until a shutdown is triggered, then runs the
+// hooks and returns their errors joined. Hooks that miss the deadline are
+// reported but not waited for. Wait may be called more than once, and
+// concurrently: the hooks run once and every call returns their result.
+func (s *Shutdown) Wait() error {
+	s.waitOnce.Do(func() {
+		s.err = s.wait()
+	})
+	return s.err
+}
+
+func (s *Shutdown) wait() error {
+	defer signal.Stop(s.sigs)
+
+	for waiting := true; waiting; {
+		select {
+		case sig := <-s.sigs:
+			if isReload(sig) {
+				s.runReload()
+				continue
+			}
+			waiting = false
+		case <-s.trigger:
+			waiting = false
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	// an impatient second signal aborts the remaining hooks
+	go func() {
+		for {
+			select {
+			case sig := <-s.sigs:
+				if !isReload(sig) {
+					cancel()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+
+	var errs []error
+	for _, h := range hooks {
+		if err := runHook(ctx, h); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", h.name, err))
+		}
+	}
+
+	close(s.done)
+	return errors.Join(errs...)
+}
+
+func (s *Shutdown) runReload() {
+	s.mu.Lock()
+	fns := s.reload
+	s.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func runHook(ctx context.Context, h shutdownHook) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		ch <- h.fn(ctx)
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,29 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !unix
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+// Without SIGHUP there is no reload signal; only the shutdown signals
+// are caught.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+func isReload(sig os.Signal) bool {
+	return false
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownDeadline(t *testing.T) {
+	s := NewShutdown(20 * time.Millisecond)
+
+	s.OnShutdown("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	ran := false
+	s.OnShutdown("late", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+
+	s.Shutdown()
+	s.Shutdown()
+
+	start := time.Now()
+	err := s.Wait()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want DeadlineExceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Wait blocked on a stuck hook")
+	}
+	if ran {
+		t.Errorf("hook ran after the deadline")
+	}
+}
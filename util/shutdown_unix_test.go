@@ -0,0 +1,67 @@
+//go:build unix
+
+package util
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	s := NewShutdown(time.Second)
+
+	var mu sync.Mutex
+	var order []string
+	for _, name := range []string{"listeners", "logger", "pool"} {
+		s.OnShutdown(name, func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			if name == "logger" {
+				return errors.New("flush failed")
+			}
+			return nil
+		})
+	}
+
+	reloaded := make(chan struct{}, 1)
+	s.OnReload(func() { reloaded <- struct{}{} })
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Wait() }()
+
+	s.sigs <- syscall.SIGHUP
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatalf("reload hook not called")
+	}
+
+	s.sigs <- syscall.SIGTERM
+	err := <-errc
+	if err == nil || err.Error() != "shutdown logger: flush failed" {
+		t.Errorf("want the logger error, got %v", err)
+	}
+	if !slices.Equal(order, []string{"listeners", "logger", "pool"}) {
+		t.Errorf("hooks ran out of order: %v", order)
+	}
+
+	select {
+	case <-s.Done():
+	default:
+		t.Errorf("Done not closed")
+	}
+
+	// later calls return the same result without running the hooks
+	if err2 := s.Wait(); err2 != err {
+		t.Errorf("second Wait: want %v, got %v", err, err2)
+	}
+	if len(order) != 3 {
+		t.Errorf("hooks ran again: %v", order)
+	}
+}
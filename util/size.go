@@ -0,0 +1,146 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSize is returned by ParseSize for malformed input.
+var ErrInvalidSize = errors.New("size: invalid value")
+
+// sizeUnits are the multiplier prefixes in increasing order.
+const sizeUnits = "KMGTPE"
+
+// ParseSize parses a byte count with an optional suffix. The suffixes
+// follow dd(1): a bare prefix ("10M") or an IEC suffix ("10MiB") is a
+// power of 1024, an SI suffix ("10MB") is a power of 1000. Suffixes are
+// case insensitive, may be separated from the number by spaces and the
+// number may have a fraction ("1.5G").
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	// split at the first non-numeric char
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, suf := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	if len(num) == 0 {
+		return 0, ErrInvalidSize
+	}
+
+	mult := int64(1)
+	if suf != "" && suf != "B" {
+		p := strings.IndexByte(sizeUnits, suf[0])
+		if p < 0 {
+			return 0, ErrInvalidSize
+		}
+
+		var base int64
+		switch suf[1:] {
+		case "", "IB":
+			base = 1024
+		case "B":
+			base = 1000
+		default:
+			return 0, ErrInvalidSize
+		}
+		for ; p >= 0; p-- {
+			mult *= base
+		}
+	}
+
+	// the integer part is exact; only the fraction goes through float64
+	ip, fp, _ := strings.Cut(num, ".")
+	if strings.Contains(fp, ".") || (ip == "" && fp == "") {
+		return 0, ErrInvalidSize
+	}
+
+	var n int64
+	if ip != "" {
+		v, err := strconv.ParseInt(ip, 10, 64)
+		if err != nil || v > math.MaxInt64/mult {
+			// ip is all digits, so the only possible error is range
+			return 0, strconv.ErrRange
+		}
+		n = v * mult
+	}
+
+	if fp != "" {
+		f, err := strconv.ParseFloat("0."+fp, 64)
+		if err != nil {
+			return 0, ErrInvalidSize
+		}
+		fn := int64(f * float64(mult))
+		if n > math.MaxInt64-fn {
+			return 0, strconv.ErrRange
+		}
+		n += fn
+	}
+	return n, nil
+}
+
+// FormatSize formats n with binary (IEC) units, e.g. "1.5 MiB". The
+// result can be parsed back with ParseSize.
+func FormatSize(n int64) string {
+	return formatSize(n, 1024, "iB")
+}
+
+// FormatSizeSI formats n with decimal (SI) units, e.g. "1.5 MB".
+func FormatSizeSI(n int64) string {
+	return formatSize(n, 1000, "B")
+}
+
+func formatSize(n int64, base float64, suffix string) string {
+	sign := ""
+	f := float64(n)
+	if n < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	if f < base {
+		return fmt.Sprintf("%s%d B", sign, int64(f))
+	}
+
+	i := -1
+	for f >= base && i < len(sizeUnits)-1 {
+		f /= base
+		i++
+	}
+	if math.Round(f*10) >= base*10 && i < len(sizeUnits)-1 {
+		// 1023.96 KiB would print as "1024 KiB"
+		f /= base
+		i++
+	}
+
+	// don't round up past what ParseSize can read back: float64(n) may
+	// already be 2^63, so step down a decimal and print MaxInt64 as
+	// "7.9 EiB", not "8 EiB"
+	if r := math.Round(f*10) / 10; r*math.Pow(base, float64(i+1)) >= math.MaxInt64 {
+		f = r - 0.1
+	}
+
+	// one decimal, dropped when it is zero
+	v := strconv.FormatFloat(f, 'f', 1, 64)
+	v = strings.TrimSuffix(v, ".0")
+	return fmt.Sprintf("%s%s %c%s", sign, v, sizeUnits[i], suffix)
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"10K", 10 << 10},
+		{"10k", 10 << 10},
+		{"10KiB", 10 << 10},
+		{"10KB", 10000},
+		{"10 MB", 10000000},
+		{"10 mib", 10 << 20},
+		{"1.5G", 3 << 29},
+		{"2T", 2 << 40},
+		{"1EiB", 1 << 60},
+		{" 7 GB ", 7000000000},
+	}
+
+	for _, tc := range tests {
+		n, err := ParseSize(tc.in)
+		if err != nil {
+			t.Errorf("%q: %s", tc.in, err)
+			continue
+		}
+		if n != tc.want {
+			t.Errorf("%q: want %d, got %d", tc.in, tc.want, n)
+		}
+	}
+
+	for _, s := range []string{"", "MB", "10X", "10MBB", "1.2.3K", "-1K"} {
+		if _, err := ParseSize(s); err != ErrInvalidSize {
+			t.Errorf("%q: want ErrInvalidSize, got %v", s, err)
+		}
+	}
+	for _, s := range []string{"16EiB", "8E", "8EiB", "7.99999999999999999E", "9223372036854775808"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("%s should overflow", s)
+		}
+	}
+	if n, err := ParseSize("9223372036854775807"); err != nil || n != math.MaxInt64 {
+		t.Errorf("MaxInt64: got %d, %v", n, err)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n       int64
+		bin, si string
+	}{
+		{0, "0 B", "0 B"},
+		{999, "999 B", "999 B"},
+		{1000, "1000 B", "1 KB"},
+		{1024, "1 KiB", "1 KB"},
+		{1536, "1.5 KiB", "1.5 KB"},
+		{1048575, "1 MiB", "1 MB"},
+		{10 << 20, "10 MiB", "10.5 MB"},
+		{-2048, "-2 KiB", "-2 KB"},
+		{math.MaxInt64, "7.9 EiB", "9.2 EB"},
+	}
+
+	for _, tc := range tests {
+		if s := FormatSize(tc.n); s != tc.bin {
+			t.Errorf("FormatSize(%d): want %q, got %q", tc.n, tc.bin, s)
+		}
+		if s := FormatSizeSI(tc.n); s != tc.si {
+			t.Errorf("FormatSizeSI(%d): want %q, got %q", tc.n, tc.si, s)
+		}
+	}
+
+	// round trip
+	for _, n := range []int64{1, 1 << 10, 5 << 20, 3 << 30} {
+		if m, err := ParseSize(FormatSize(n)); err != nil || m != n {
+			t.Errorf("round trip %d: got %d, %v", n, m, err)
+		}
+	}
+
+	// the largest sizes must still parse, if not exactly
+	for _, n := range []int64{math.MaxInt64, math.MaxInt64 - 1<<59} {
+		if _, err := ParseSize(FormatSize(n)); err != nil {
+			t.Errorf("round trip %d: %v", n, err)
+		}
+		if _, err := ParseSize(FormatSizeSI(n)); err != nil {
+			t.Errorf("SI round trip %d: %v", n, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpinLock is a test-and-test-and-set lock padded to a cache line of its
+// own, so that neighbouring hot fields don't false-share with it. Waiters
+// back off exponentially and yield the processor once spinning gets
+// long. It only pays off for very short critical sections; use
+// sync.Mutex otherwise. The zero value is unlocked.
+type SpinLock struct {
+	_ [cacheLine]byte
+	v atomic.Uint32
+	_ [cacheLine - 4]byte
+}
+
+// spinMax caps the busy-wait between attempts; beyond it waiters yield.
+const spinMax = 64
+
+// Lock acquires l, spinning until it is free.
+func (l *SpinLock) Lock() {
+	n := 1
+	for !l.TryLock() {
+		for l.v.Load() != 0 {
+			if n > spinMax {
+				runtime.Gosched()
+				continue
+			}
+			for i := 0; i < n; i++ {
+				// read-only spin keeps the line shared until unlock
+				if l.v.Load() == 0 {
+					break
+				}
+			}
+			n <<= 1
+		}
+	}
+}
+
+// TryLock acquires l if it is free and reports whether it did.
+func (l *SpinLock) TryLock() bool {
+	return l.v.Load() == 0 && l.v.CompareAndSwap(0, 1)
+}
+
+// Unlock releases l. It panics if l is not locked.
+func (l *SpinLock) Unlock() {
+	if l.v.Swap(0) == 0 {
+		panic("spinlock: unlock of unlocked lock")
+	}
+}
+
+// MutexStats are the counters of a ProfiledMutex.
+type MutexStats struct {
+	Locks    uint64        // total acquisitions
+	Waits    uint64        // acquisitions that found the mutex held
+	WaitTime time.Duration // total time spent waiting
+	MaxWait  time.Duration // longest single wait
+}
+
+// ProfiledMutex is a sync.Mutex that records how often and how long
+// Lock had to wait, to find out which locks are worth tuning. Waits
+// longer than Threshold are reported through Logf. The zero value is an
+// unlocked mutex that only counts.
+type ProfiledMutex struct {
+	mu sync.Mutex
+
+	// Name identifies the mutex in log messages.
+	Name string
+
+	// Threshold and Logf enable logging of long waits; log.Printf is a
+	// suitable Logf. Both must be set before first use.
+	Threshold time.Duration
+	Logf      func(format string, args ...interface{})
+
+	locks   atomic.Uint64
+	waits   atomic.Uint64
+	waitNs  atomic.Int64
+	maxWait atomic.Int64
+}
+
+// Lock acquires m, recording the wait if it is held.
+func (m *ProfiledMutex) Lock() {
+	m.locks.Add(1)
+	if m.mu.TryLock() {
+		return
+	}
+
+	t0 := time.Now()
+	m.mu.Lock()
+	d := time.Since(t0)
+
+	m.waits.Add(1)
+	m.waitNs.Add(int64(d))
+	for {
+		o := m.maxWait.Load()
+		if int64(d) <= o || m.maxWait.CompareAndSwap(o, int64(d)) {
+			break
+		}
+	}
+
+	if m.Logf != nil && m.Threshold > 0 && d > m.Threshold {
+		m.Logf("mutex %s: waited %s (waits=%d/%d)", m.Name, d, m.waits.Load(), m.locks.Load())
+	}
+}
+
+// TryLock acquires m if it is free and reports whether it did.
+func (m *ProfiledMutex) TryLock() bool {
+	if m.mu.TryLock() {
+		m.locks.Add(1)
+		return true
+	}
+	return false
+}
+
+// Unlock releases m.
+func (m *ProfiledMutex) Unlock() {
+	m.mu.Unlock()
+}
+
+// Stats returns the counters so far.
+func (m *ProfiledMutex) Stats() MutexStats {
+	return MutexStats{
+		Locks:    m.locks.Load(),
+		Waits:    m.waits.Load(),
+		WaitTime: time.Duration(m.waitNs.Load()),
+		MaxWait:  time.Duration(m.maxWait.Load()),
+	}
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestSpinLock(t *testing.T) {
+	if s := unsafe.Sizeof(SpinLock{}); s < 2*cacheLine {
+		t.Errorf("SpinLock is %d bytes, not padded", s)
+	}
+
+	var l SpinLock
+	n := 0
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Lock()
+				n++
+				l.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if n != 8000 {
+		t.Errorf("want 8000, got %d", n)
+	}
+
+	if !l.TryLock() || l.TryLock() {
+		t.Error("TryLock")
+	}
+	l.Unlock()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("unlock of unlocked lock didn't panic")
+		}
+	}()
+	l.Unlock()
+}
+
+func TestProfiledMutex(t *testing.T) {
+	var mu sync.Mutex
+	var logged []string
+	m := &ProfiledMutex{
+		Name:      "test",
+		Threshold: 5 * time.Millisecond,
+		Logf: func(f string, args ...interface{}) {
+			mu.Lock()
+			logged = append(logged, fmt.Sprintf(f, args...))
+			mu.Unlock()
+		},
+	}
+
+	m.Lock()
+	m.Unlock()
+	if s := m.Stats(); s.Locks != 1 || s.Waits != 0 {
+		t.Errorf("uncontended: %+v", s)
+	}
+
+	m.Lock()
+	done := make(chan struct{})
+	go func() {
+		m.Lock()
+		m.Unlock()
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if m.TryLock() {
+		t.Error("TryLock on a held mutex")
+	}
+	m.Unlock()
+	<-done
+
+	s := m.Stats()
+	if s.Locks != 3 || s.Waits != 1 || s.MaxWait < 10*time.Millisecond || s.WaitTime != s.MaxWait {
+		t.Errorf("contended: %+v", s)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(logged) != 1 || !strings.HasPrefix(logged[0], "mutex test: waited ") {
+		t.Errorf("want one log line, got %q", logged)
+	}
+}
+
+func BenchmarkSpinLock(b *testing.B) {
+	var l SpinLock
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Lock()
+			l.Unlock()
+		}
+	})
+}
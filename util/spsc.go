@@ -0,0 +1,135 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// cacheLine is the padding used to keep hot fields on separate cache
+// lines; 128 covers adjacent line prefetching on x86 and arm64.
+const cacheLine = 128
+
+// SPSC is a lock-free bounded queue for exactly one producer goroutine
+// and one consumer goroutine. The producer and consumer indices live on
+// separate cache lines, and each side caches the other's index so that
+// the shared lines are only touched when the queue looks full or empty.
+type SPSC[T any] struct {
+	_    [cacheLine]byte
+	head atomic.Uint64 // next slot to read; written by the consumer
+	tail uint64        // consumer's cached copy of tail
+	_    [cacheLine - 16]byte
+
+	ptail  atomic.Uint64 // next slot to write; written by the producer
+	phead  uint64        // producer's cached copy of head
+	closed atomic.Bool
+	_      [cacheLine - 20]byte
+
+	mask uint64
+	buf  []T
+}
+
+// NewSPSC returns a queue holding size elements, rounded up to a power
+// of two.
+func NewSPSC[T any](size int) *SPSC[T] {
+	n := uint64(1)
+	for n < uint64(size) {
+		n <<= 1
+	}
+	return &SPSC[T]{
+		mask: n - 1,
+		buf:  make([]T, n),
+	}
+}
+
+// TryPush adds v to the queue and returns false if the queue is full.
+// It must only be called by the producer.
+func (q *SPSC[T]) TryPush(v T) bool {
+	t := q.ptail.Load()
+	if t-q.phead > q.mask {
+		q.phead = q.head.Load()
+		if t-q.phead > q.mask {
+			return false
+		}
+	}
+
+	q.buf[t&q.mask] = v
+	q.ptail.Store(t + 1)
+	return true
+}
+
+// Push adds v to the queue, spinning while it is full. It returns false
+// if the queue was closed.
+func (q *SPSC[T]) Push(v T) bool {
+	for !q.TryPush(v) {
+		if q.closed.Load() {
+			return false
+		}
+		runtime.Gosched()
+	}
+	return true
+}
+
+// TryPop removes the oldest element and returns false if the queue is
+// empty. It must only be called by the consumer.
+func (q *SPSC[T]) TryPop() (T, bool) {
+	var zero T
+
+	h := q.head.Load()
+	if h == q.tail {
+		q.tail = q.ptail.Load()
+		if h == q.tail {
+			return zero, false
+		}
+	}
+
+	i := h & q.mask
+	v := q.buf[i]
+	q.buf[i] = zero
+	q.head.Store(h + 1)
+	return v, true
+}
+
+// Pop removes the oldest element, spinning while the queue is empty. It
+// returns false once the queue is closed and drained.
+func (q *SPSC[T]) Pop() (T, bool) {
+	for {
+		if v, ok := q.TryPop(); ok {
+			return v, true
+		}
+		if q.closed.Load() {
+			// the producer may have pushed just before closing
+			return q.TryPop()
+		}
+		runtime.Gosched()
+	}
+}
+
+// Close marks the end of the stream; Pop drains what is left and then
+// returns false.
+func (q *SPSC[T]) Close() {
+	q.closed.Store(true)
+}
+
+// Len returns the number of queued elements; it is only a snapshot when
+// called concurrently with Push or Pop.
+func (q *SPSC[T]) Len() int {
+	return int(q.ptail.Load() - q.head.Load())
+}
+
+// Cap returns the capacity of the queue.
+func (q *SPSC[T]) Cap() int {
+	return len(q.buf)
+}
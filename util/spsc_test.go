@@ -0,0 +1,80 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestSPSC(t *testing.T) {
+	q := NewSPSC[int](5)
+	if q.Cap() != 8 {
+		t.Fatalf("want capacity 8, got %d", q.Cap())
+	}
+
+	for i := 0; i < 8; i++ {
+		if !q.TryPush(i) {
+			t.Fatalf("push %d failed", i)
+		}
+	}
+	if q.TryPush(8) {
+		t.Fatalf("push to a full queue succeeded")
+	}
+	if q.Len() != 8 {
+		t.Errorf("want len 8, got %d", q.Len())
+	}
+
+	for i := 0; i < 8; i++ {
+		v, ok := q.TryPop()
+		if !ok || v != i {
+			t.Fatalf("pop %d: got %d, %v", i, v, ok)
+		}
+	}
+	if _, ok := q.TryPop(); ok {
+		t.Fatalf("pop from an empty queue succeeded")
+	}
+}
+
+func TestSPSCConcurrent(t *testing.T) {
+	const n = 200000
+	q := NewSPSC[int](64)
+
+	go func() {
+		for i := 0; i < n; i++ {
+			q.Push(i)
+		}
+		q.Close()
+	}()
+
+	want := 0
+	for {
+		v, ok := q.Pop()
+		if !ok {
+			break
+		}
+		if v != want {
+			t.Fatalf("want %d, got %d", want, v)
+		}
+		want++
+	}
+	if want != n {
+		t.Errorf("want %d elements, got %d", n, want)
+	}
+}
+
+func BenchmarkSPSC(b *testing.B) {
+	q := NewSPSC[int](1024)
+	done := make(chan struct{})
+	go func() {
+		for {
+			if _, ok := q.Pop(); !ok {
+				close(done)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < b.N; i++ {
+		q.Push(i)
+	}
+	q.Close()
+	<-done
+}
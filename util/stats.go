@@ -0,0 +1,228 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EWMA is an exponentially weighted moving average of samples. It is
+// safe for concurrent use.
+type EWMA struct {
+	mu    sync.Mutex
+	alpha float64
+	v     float64
+	init  bool
+}
+
+// NewEWMA returns an average where each new sample has weight alpha
+// (0 < alpha <= 1); the first sample initializes the average.
+func NewEWMA(alpha float64) *EWMA {
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.1
+	}
+	return &EWMA{alpha: alpha}
+}
+
+// NewEWMASamples returns an average that roughly tracks the last n
+// samples (alpha = 2/(n+1)).
+func NewEWMASamples(n int) *EWMA {
+	return NewEWMA(2 / (float64(max(n, 1)) + 1))
+}
+
+// Add adds a sample.
+func (e *EWMA) Add(x float64) {
+	e.mu.Lock()
+	if e.init {
+		e.v += e.alpha * (x - e.v)
+	} else {
+		e.v, e.init = x, true
+	}
+	e.mu.Unlock()
+}
+
+// Value returns the current average, or 0 before the first sample.
+func (e *EWMA) Value() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.v
+}
+
+// Rate is an exponentially decaying event rate in events per second,
+// like the load average: events older than about the time constant tau
+// fade out. It needs no background ticker. It is safe for concurrent use.
+type Rate struct {
+	mu   sync.Mutex
+	tau  float64 // seconds
+	r    float64
+	last time.Time
+	now  func() time.Time
+}
+
+// NewRate returns a rate with time constant tau.
+func NewRate(tau time.Duration) *Rate {
+	if tau <= 0 {
+		tau = time.Minute
+	}
+	r := &Rate{
+		tau: tau.Seconds(),
+		now: time.Now,
+	}
+	r.last = r.now()
+	return r
+}
+
+// Mark records n events now.
+func (r *Rate) Mark(n float64) {
+	r.mu.Lock()
+	now := r.now()
+	r.r = r.decay(now) + n/r.tau
+	r.last = now
+	r.mu.Unlock()
+}
+
+// Rate returns the current rate in events per second.
+func (r *Rate) Rate() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.decay(r.now())
+}
+
+// decay must be called with the lock held.
+func (r *Rate) decay(now time.Time) float64 {
+	dt := now.Sub(r.last).Seconds()
+	if dt <= 0 {
+		return r.r
+	}
+	return r.r * math.Exp(-dt/r.tau)
+}
+
+// Quantile estimates a single quantile of a stream in constant space
+// with the P² algorithm (Jain & Chlamtac, 1985). It is safe for
+// concurrent use.
+type Quantile struct {
+	mu sync.Mutex
+	p  float64
+	n  int
+
+	q   [5]float64 // marker heights
+	pos [5]float64 // actual marker positions, 1-based
+	des [5]float64 // desired marker positions
+	inc [5]float64 // desired position increments
+}
+
+// NewQuantile returns an estimator for the p-quantile, 0 < p < 1 (0.99
+// for the 99th percentile).
+func NewQuantile(p float64) *Quantile {
+	if p <= 0 || p >= 1 {
+		p = 0.5
+	}
+	return &Quantile{
+		p:   p,
+		pos: [5]float64{1, 2, 3, 4, 5},
+		des: [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5},
+		inc: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+// Add adds an observation.
+func (e *Quantile) Add(x float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.n < 5 {
+		e.q[e.n] = x
+		e.n++
+		if e.n == 5 {
+			sort.Float64s(e.q[:])
+		}
+		return
+	}
+	e.n++
+
+	// find the cell k holding x, extending the extremes if needed
+	var k int
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+		k = 0
+	case x >= e.q[4]:
+		e.q[4] = max(e.q[4], x)
+		k = 3
+	default:
+		for k = 0; k < 3 && x >= e.q[k+1]; k++ {
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		e.pos[i]++
+	}
+	for i := range e.des {
+		e.des[i] += e.inc[i]
+	}
+
+	// nudge the middle markers towards their desired positions
+	for i := 1; i < 4; i++ {
+		d := e.des[i] - e.pos[i]
+		if (d >= 1 && e.pos[i+1]-e.pos[i] > 1) || (d <= -1 && e.pos[i-1]-e.pos[i] < -1) {
+			s := math.Copysign(1, d)
+			h := e.parabolic(i, s)
+			if e.q[i-1] < h && h < e.q[i+1] {
+				e.q[i] = h
+			} else {
+				e.q[i] = e.linear(i, s)
+			}
+			e.pos[i] += s
+		}
+	}
+}
+
+func (e *Quantile) parabolic(i int, s float64) float64 {
+	n, q := e.pos, e.q
+	return q[i] + s/(n[i+1]-n[i-1])*
+		((n[i]-n[i-1]+s)*(q[i+1]-q[i])/(n[i+1]-n[i])+
+			(n[i+1]-n[i]-s)*(q[i]-q[i-1])/(n[i]-n[i-1]))
+}
+
+func (e *Quantile) linear(i int, s float64) float64 {
+	j := i + int(s)
+	return e.q[i] + s*(e.q[j]-e.q[i])/(e.pos[j]-e.pos[i])
+}
+
+// Value returns the current estimate; with fewer than five observations
+// it is the exact quantile of what has been seen.
+func (e *Quantile) Value() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.n == 0 {
+		return 0
+	}
+	if e.n < 5 {
+		s := append([]float64(nil), e.q[:e.n]...)
+		sort.Float64s(s)
+		return s[int(math.Round(e.p*float64(e.n-1)))]
+	}
+	return e.q[2]
+}
+
+// Count returns the number of observations.
+func (e *Quantile) Count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.n
+}
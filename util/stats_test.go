@@ -0,0 +1,80 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestEWMA(t *testing.T) {
+	e := NewEWMA(0.5)
+	if e.Value() != 0 {
+		t.Fatalf("want 0 before samples")
+	}
+
+	e.Add(10)
+	e.Add(20)
+	e.Add(20)
+	if v := e.Value(); v != 17.5 {
+		t.Errorf("want 17.5, got %g", v)
+	}
+
+	s := NewEWMASamples(10)
+	for i := 0; i < 200; i++ {
+		s.Add(5)
+	}
+	if v := s.Value(); v != 5 {
+		t.Errorf("constant input: want 5, got %g", v)
+	}
+}
+
+func TestRate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	r := NewRate(10 * time.Second)
+	r.now = func() time.Time { return now }
+	r.last = now
+
+	// a steady 100/s converges to 100
+	for i := 0; i < 10000; i++ {
+		now = now.Add(10 * time.Millisecond)
+		r.Mark(1)
+	}
+	if v := r.Rate(); math.Abs(v-100) > 2 {
+		t.Errorf("want about 100/s, got %g", v)
+	}
+
+	// and decays by 1/e per time constant once events stop
+	before := r.Rate()
+	now = now.Add(10 * time.Second)
+	if v := r.Rate(); math.Abs(v-before/math.E) > 1e-9 {
+		t.Errorf("want %g after one time constant, got %g", before/math.E, v)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, p := range []float64{0.5, 0.9, 0.99} {
+		q := NewQuantile(p)
+		for i := 0; i < 100000; i++ {
+			q.Add(rnd.Float64() * 1000)
+		}
+
+		want := p * 1000
+		if v := q.Value(); math.Abs(v-want) > 10 {
+			t.Errorf("p%g of uniform[0,1000): want about %g, got %g", p*100, want, v)
+		}
+		if q.Count() != 100000 {
+			t.Errorf("bad count %d", q.Count())
+		}
+	}
+
+	small := NewQuantile(0.5)
+	for _, x := range []float64{3, 1, 2} {
+		small.Add(x)
+	}
+	if v := small.Value(); v != 2 {
+		t.Errorf("median of 1,2,3: want 2, got %g", v)
+	}
+}
@@ -0,0 +1,94 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	crand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// The token helpers draw from crypto/rand; bits is the entropy of the
+// token, rounded up to whole bytes.
+
+// TokenHex returns a random token of at least bits entropy in hex.
+func TokenHex(bits int) string {
+	return hex.EncodeToString(randBytes(bits))
+}
+
+// TokenBase32 returns a random token in lower case, unpadded base32;
+// it is case insensitive and safe in file names.
+func TokenBase32(bits int) string {
+	return Base32Encode(randBytes(bits))
+}
+
+// TokenBase64 returns a random token in unpadded, URL safe base64.
+func TokenBase64(bits int) string {
+	return base64.RawURLEncoding.EncodeToString(randBytes(bits))
+}
+
+// TempName returns prefix + a random string + suffix, for naming
+// temporary files; it carries 40 bits of entropy.
+func TempName(prefix, suffix string) string {
+	return prefix + TokenBase32(40) + suffix
+}
+
+// Passphrase returns n words chosen uniformly from list, joined by sep.
+// With a diceware list of 7776 words each word adds 12.9 bits of
+// entropy. If list is empty, pronounceable made-up words of three
+// consonant-vowel syllables are used instead; each adds 18.9 bits.
+func Passphrase(n int, list []string, sep string) string {
+	w := make([]string, n)
+	for i := range w {
+		if len(list) > 0 {
+			w[i] = list[randIntn(len(list))]
+		} else {
+			w[i] = pronounceable(3)
+		}
+	}
+	return strings.Join(w, sep)
+}
+
+const (
+	consonants = "bdfghjklmnprstvz"
+	vowels     = "aeiou"
+)
+
+func pronounceable(syllables int) string {
+	b := make([]byte, 0, 2*syllables)
+	for i := 0; i < syllables; i++ {
+		b = append(b, consonants[randIntn(len(consonants))], vowels[randIntn(len(vowels))])
+	}
+	return string(b)
+}
+
+func randBytes(bits int) []byte {
+	b := make([]byte, (max(bits, 8)+7)/8)
+	crand.Read(b)
+	return b
+}
+
+// randIntn returns a uniform random number in [0, n) without modulo bias.
+func randIntn(n int) int {
+	var b [8]byte
+	lim := ^uint64(0) - ^uint64(0)%uint64(n)
+	for {
+		crand.Read(b[:])
+		if v := binary.LittleEndian.Uint64(b[:]); v < lim {
+			return int(v % uint64(n))
+		}
+	}
+}
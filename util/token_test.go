@@ -0,0 +1,59 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		tok string
+		re  string
+	}{
+		{TokenHex(128), `^[0-9a-f]{32}$`},
+		{TokenHex(12), `^[0-9a-f]{4}$`},
+		{TokenBase32(160), `^[a-z2-7]{32}$`},
+		{TokenBase64(256), `^[A-Za-z0-9_-]{43}$`},
+		{TempName("log.", ".gz"), `^log\.[a-z2-7]{8}\.gz$`},
+	}
+	for _, tc := range tests {
+		if !regexp.MustCompile(tc.re).MatchString(tc.tok) {
+			t.Errorf("%q doesn't match %s", tc.tok, tc.re)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		s := TokenHex(64)
+		if seen[s] {
+			t.Fatalf("duplicate token %s", s)
+		}
+		seen[s] = true
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	p := Passphrase(5, nil, "-")
+	w := strings.Split(p, "-")
+	if len(w) != 5 {
+		t.Fatalf("want 5 words, got %q", p)
+	}
+	re := regexp.MustCompile(`^([bdfghjklmnprstvz][aeiou]){3}$`)
+	for _, x := range w {
+		if !re.MatchString(x) {
+			t.Errorf("bad word %q", x)
+		}
+	}
+
+	list := []string{"apple", "pear", "plum"}
+	counts := make(map[string]int)
+	for _, x := range strings.Fields(Passphrase(3000, list, " ")) {
+		counts[x]++
+	}
+	for _, x := range list {
+		if counts[x] < 850 || counts[x] > 1150 {
+			t.Errorf("skewed choice: %v", counts)
+		}
+	}
+}
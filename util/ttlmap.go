@@ -0,0 +1,163 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLMap is a concurrent map whose entries expire after a per-entry or
+// default lifetime. Expired entries are invisible immediately and are
+// reclaimed by a background sweeper.
+type TTLMap[K comparable, V any] struct {
+	mu  sync.Mutex
+	m   map[K]ttlEntry[V]
+	ttl time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+type ttlEntry[V any] struct {
+	val    V
+	expire time.Time
+}
+
+// NewTTLMap returns a TTLMap whose entries live for ttl by default; the
+// sweeper runs every sweep interval (or every ttl if sweep is zero). Call
+// Close to stop the sweeper.
+func NewTTLMap[K comparable, V any](ttl, sweep time.Duration) *TTLMap[K, V] {
+	if sweep <= 0 {
+		sweep = ttl
+	}
+	if sweep <= 0 {
+		sweep = time.Minute
+	}
+
+	t := &TTLMap[K, V]{
+		m:    make(map[K]ttlEntry[V]),
+		ttl:  ttl,
+		stop: make(chan struct{}),
+	}
+
+	t.wg.Add(1)
+	go t.sweeper(sweep)
+	return t
+}
+
+// Get returns the unexpired value stored under k.
+func (t *TTLMap[K, V]) Get(k K) (V, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.get(k, time.Now())
+}
+
+// Set stores v under k with the default TTL.
+func (t *TTLMap[K, V]) Set(k K, v V) {
+	t.SetTTL(k, v, t.ttl)
+}
+
+// SetTTL stores v under k for the duration ttl; a ttl of zero means the
+// entry never expires.
+func (t *TTLMap[K, V]) SetTTL(k K, v V, ttl time.Duration) {
+	t.mu.Lock()
+	t.m[k] = ttlEntry[V]{val: v, expire: deadline(time.Now(), ttl)}
+	t.mu.Unlock()
+}
+
+// LoadOrStore returns the existing unexpired value for k if present;
+// otherwise it stores v with the default TTL and returns it. loaded is
+// true if the value was already present.
+func (t *TTLMap[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if old, ok := t.get(k, now); ok {
+		return old, true
+	}
+
+	t.m[k] = ttlEntry[V]{val: v, expire: deadline(now, t.ttl)}
+	return v, false
+}
+
+// Delete removes k from the map.
+func (t *TTLMap[K, V]) Delete(k K) {
+	t.mu.Lock()
+	delete(t.m, k)
+	t.mu.Unlock()
+}
+
+// Len returns the number of entries, including expired entries that
+// haven't been swept yet.
+func (t *TTLMap[K, V]) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.m)
+}
+
+// Close stops the background sweeper. The map remains usable but expired
+// entries are only removed when looked up.
+func (t *TTLMap[K, V]) Close() {
+	t.once.Do(func() {
+		close(t.stop)
+	})
+	t.wg.Wait()
+}
+
+// get must be called with the lock held.
+func (t *TTLMap[K, V]) get(k K, now time.Time) (V, bool) {
+	e, ok := t.m[k]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !e.expire.IsZero() && !now.Before(e.expire) {
+		delete(t.m, k)
+		var zero V
+		return zero, false
+	}
+	return e.val, true
+}
+
+func (t *TTLMap[K, V]) sweeper(every time.Duration) {
+	defer t.wg.Done()
+
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-tick.C:
+			t.mu.Lock()
+			for k, e := range t.m {
+				if !e.expire.IsZero() && !now.Before(e.expire) {
+					delete(t.m, k)
+				}
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+func deadline(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
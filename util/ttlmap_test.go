@@ -0,0 +1,44 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLMap(t *testing.T) {
+	m := NewTTLMap[string, int](30*time.Millisecond, 10*time.Millisecond)
+	defer m.Close()
+
+	m.Set("a", 1)
+	m.SetTTL("forever", 2, 0)
+
+	if v, ok := m.Get("a"); !ok || v != 1 {
+		t.Fatalf("get a: got %d %v", v, ok)
+	}
+
+	if v, loaded := m.LoadOrStore("a", 5); !loaded || v != 1 {
+		t.Errorf("LoadOrStore existing: got %d %v", v, loaded)
+	}
+	if v, loaded := m.LoadOrStore("b", 5); loaded || v != 5 {
+		t.Errorf("LoadOrStore new: got %d %v", v, loaded)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, ok := m.Get("a"); ok {
+		t.Errorf("a should have expired")
+	}
+	if v, ok := m.Get("forever"); !ok || v != 2 {
+		t.Errorf("forever: got %d %v", v, ok)
+	}
+
+	// the sweeper must have reclaimed "b" without a lookup
+	if n := m.Len(); n != 1 {
+		t.Errorf("want 1 entry after sweep, got %d", n)
+	}
+
+	m.Delete("forever")
+	if _, ok := m.Get("forever"); ok {
+		t.Errorf("forever should be deleted")
+	}
+}
@@ -0,0 +1,113 @@
+// This program is free software: you can redistribute it and/or modify it
+// under the terms of the GNU General Public License as published by the Free
+// Software Foundation, either version 3 of the License, or (at your option)
+// any later version.
+//
+// This program is distributed in the hope that it will be useful, but
+// WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General
+// Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// WindowCounter counts events in a trailing time window using a ring of
+// buckets. The window slides one bucket at a time, so counts are exact to
+// within one bucket width. It is safe for concurrent use.
+type WindowCounter struct {
+	mu      sync.Mutex
+	buckets []int64
+	width   time.Duration
+	last    int64 // absolute index (time / width) of the newest bucket
+	total   int64
+	now     func() time.Time
+}
+
+// NewWindowCounter returns a counter over window split into n buckets.
+func NewWindowCounter(window time.Duration, n int) *WindowCounter {
+	if n <= 0 {
+		n = 10
+	}
+	width := window / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+
+	w := &WindowCounter{
+		buckets: make([]int64, n),
+		width:   width,
+		now:     time.Now,
+	}
+	w.last = w.index(w.now())
+	return w
+}
+
+// Add records n events now.
+func (w *WindowCounter) Add(n int64) {
+	w.mu.Lock()
+	w.advance()
+	w.buckets[w.last%int64(len(w.buckets))] += n
+	w.total += n
+	w.mu.Unlock()
+}
+
+// Count returns the number of events in the trailing window.
+func (w *WindowCounter) Count() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.advance()
+	return w.total
+}
+
+// Rate returns the average number of events per second over the window.
+func (w *WindowCounter) Rate() float64 {
+	n := w.Count()
+	return float64(n) / (w.width * time.Duration(len(w.buckets))).Seconds()
+}
+
+// Window returns the span covered by the counter.
+func (w *WindowCounter) Window() time.Duration {
+	return w.width * time.Duration(len(w.buckets))
+}
+
+// Reset clears all buckets.
+func (w *WindowCounter) Reset() {
+	w.mu.Lock()
+	clear(w.buckets)
+	w.total = 0
+	w.mu.Unlock()
+}
+
+func (w *WindowCounter) index(t time.Time) int64 {
+	return t.UnixNano() / int64(w.width)
+}
+
+// advance expires the buckets that slid out of the window. It must be
+// called with the lock held.
+func (w *WindowCounter) advance() {
+	cur := w.index(w.now())
+	if cur <= w.last {
+		return
+	}
+
+	n := int64(len(w.buckets))
+	if cur-w.last >= n {
+		clear(w.buckets)
+		w.total = 0
+	} else {
+		for i := w.last + 1; i <= cur; i++ {
+			b := &w.buckets[i%n]
+			w.total -= *b
+			*b = 0
+		}
+	}
+	w.last = cur
+}
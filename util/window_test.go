@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCounter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	w := NewWindowCounter(10*time.Second, 10)
+	w.now = func() time.Time { return now }
+	w.last = w.index(now)
+
+	// one event per second for 10 seconds
+	for i := 0; i < 10; i++ {
+		w.Add(1)
+		now = now.Add(time.Second)
+	}
+	if n := w.Count(); n != 9 {
+		t.Errorf("want 9 events in the window, got %d", n)
+	}
+
+	now = now.Add(3 * time.Second)
+	if n := w.Count(); n != 6 {
+		t.Errorf("want 6 events after 3s, got %d", n)
+	}
+	if r := w.Rate(); r != 0.6 {
+		t.Errorf("want 0.6/s, got %g", r)
+	}
+
+	// a long gap clears everything
+	now = now.Add(time.Hour)
+	if n := w.Count(); n != 0 {
+		t.Errorf("want 0 after an hour, got %d", n)
+	}
+
+	w.Add(5)
+	w.Reset()
+	if n := w.Count(); n != 0 {
+		t.Errorf("want 0 after reset, got %d", n)
+	}
+	if w.Window() != 10*time.Second {
+		t.Errorf("bad window %s", w.Window())
+	}
+}